@@ -1,20 +1,34 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"nexzap/internal/db"
 	"nexzap/internal/handlers"
+	"nexzap/internal/metrics"
 	"nexzap/internal/services"
+	"nexzap/internal/services/container"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// time given to the submissions in flight to finish when the server stops
+const SHUTDOWN_TIMEOUT = 90 * time.Second
+
 func main() {
 	_ = godotenv.Load(".env")
 
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
+
 	database, err := db.NewDatabase()
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
@@ -33,8 +47,14 @@ func main() {
 	}
 	sheetService := services.NewSheetService(database)
 	markdownService := services.NewMarkdownParser()
-	importService := services.NewImportService(database)
+	builder, err := container.NewEngineFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to connect to Docker: %v", err)
+	}
+	importService := services.NewImportService(database, builder)
 	historyService := services.NewHistoryService(database)
+	poolPolicyService := services.NewPoolPolicyService(database)
+	exerciseService.SetPoolPolicy(poolPolicyService.PoolPolicy)
 
 	app := handlers.NewApp(
 		database,
@@ -63,8 +83,50 @@ func main() {
 
 	// Start the server
 	port := "8080"
-	fmt.Println("Server running on port " + port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	server := &http.Server{Addr: ":" + port}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		fmt.Println("Server running on port " + port)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	// Serve the metrics apart from the learners
+	metricsServer := &http.Server{
+		Addr:    metrics.Addr(),
+		Handler: metrics.Handler(os.Getenv("METRICS_TOKEN")),
+	}
+	go func() {
+		fmt.Println("Metrics served on " + metricsServer.Addr)
+		if err := metricsServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Metrics server failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	shutdown(server, metricsServer, exerciseService)
+}
+
+// shutdown refuses new submissions, waits for the ones in flight, then stops
+// the servers and removes the pooled containers.
+func shutdown(server, metricsServer *http.Server, exerciseService *services.ExerciseService) {
+	fmt.Println("Shutting down, waiting for the submissions in flight")
+	ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+
+	if err := exerciseService.Drain(ctx); err != nil {
+		log.Printf("Submissions still running after %s: %v", SHUTDOWN_TIMEOUT, err)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down the server: %v", err)
+	}
+	if err := metricsServer.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down the metrics server: %v", err)
+	}
+	if err := exerciseService.Cleanup(); err != nil {
+		log.Printf("Failed to clean the containers: %v", err)
 	}
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"nexzap/internal/db"
+	"nexzap/internal/services"
+	"nexzap/internal/services/container"
+	"strings"
+	"time"
+)
+
+// validate checks a tutorial directory before publishing it and returns the exit code.
+// The tutorial is imported in a scratch schema dropped afterwards.
+func validate(args []string) int {
+	if len(args) != 1 {
+		fmt.Println("Usage: nexzap validate <tutorial-dir>")
+		return 2
+	}
+	dir := args[0]
+
+	database, err := db.NewScratchDatabase(fmt.Sprintf("validate_%d", time.Now().UnixNano()))
+	if err != nil {
+		log.Printf("Failed to initialize database: %v", err)
+		return 1
+	}
+	defer database.Close()
+	defer func() {
+		if err := database.DropSchema(); err != nil {
+			log.Println(err)
+		}
+	}()
+	if err := database.Populate(); err != nil {
+		log.Printf("Failed to populate database: %v", err)
+		return 1
+	}
+
+	// Keep the containers out of reach of the reaper of a server on the same host
+	container.App = "nexzap-validate"
+	rt, err := container.NewEngineFromEnv()
+	if err != nil {
+		log.Printf("Failed to connect to Docker: %v", err)
+		return 1
+	}
+	exerciseService := services.NewExerciseServiceWithRuntime(rt)
+	defer exerciseService.Cleanup()
+	sheetService := services.NewSheetService(database)
+	importService := services.NewImportService(database, rt)
+	validateService := services.NewValidateService(database, importService, exerciseService)
+
+	fmt.Printf("Validating %s\n", dir)
+	validations, err := validateService.Validate(dir)
+	if err != nil {
+		log.Printf("Failed to validate %s: %v", dir, err)
+		return 1
+	}
+
+	code := 0
+	for _, v := range validations {
+		if v.Ok() {
+			fmt.Printf("  sheet %d: ok\n", v.Page)
+			continue
+		}
+		code = 1
+		fmt.Printf("  sheet %d: FAIL\n", v.Page)
+		for _, problem := range v.Problems() {
+			fmt.Printf("    - %s\n", problem)
+		}
+		if v.Correction.StatusCode != 0 {
+			fmt.Println(indent(sheetService.Sanitize(v.Correction.Output), "      "))
+		}
+	}
+	return code
+}
+
+func indent(text, prefix string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	return prefix + strings.Join(lines, "\n"+prefix)
+}
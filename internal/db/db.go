@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -13,6 +14,7 @@ import (
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -26,12 +28,31 @@ const (
 
 // Database struct to encapsulate the connection pool and repository
 type Database struct {
-	pool *pgxpool.Pool
-	repo *generated.Queries
+	pool   *pgxpool.Pool
+	repo   *generated.Queries
+	schema string
 }
 
 // NewDatabase initializes the Database struct with connection pooling and retries
 func NewDatabase() (*Database, error) {
+	return newDatabase("public")
+}
+
+// NewScratchDatabase creates a database working in a new schema, isolated from the
+// tutorials of the server. The schema must be dropped with DropSchema once done.
+func NewScratchDatabase(schema string) (*Database, error) {
+	d, err := newDatabase(schema)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.pool.Exec(context.Background(), "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		d.Close()
+		return nil, fmt.Errorf("failed to create schema %s: %v", schema, err)
+	}
+	return d, nil
+}
+
+func newDatabase(schema string) (*Database, error) {
 	var err error
 	creds := getCredentials()
 	connStr := fmt.Sprintf(
@@ -60,13 +81,23 @@ func NewDatabase() (*Database, error) {
 
 	poolConfig, _ := pgxpool.ParseConfig(connStr)
 	poolConfig.MaxConns = 20
+	// public stays in the path for the functions of the extensions
+	poolConfig.ConnConfig.RuntimeParams["search_path"] = searchPath(schema)
 	pool, err = pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %v", err)
 	}
 
 	repository := generated.New(pool)
-	return &Database{pool: pool, repo: repository}, nil
+	return &Database{pool: pool, repo: repository, schema: schema}, nil
+}
+
+// searchPath returns the search_path of a schema.
+func searchPath(schema string) string {
+	if schema == "public" {
+		return "public"
+	}
+	return pgx.Identifier{schema}.Sanitize() + ",public"
 }
 
 func (d *Database) GetRepository() *generated.Queries {
@@ -80,12 +111,13 @@ func (d *Database) Close() {
 func (d *Database) Populate() error {
 	creds := getCredentials()
 	connStrMigration := fmt.Sprintf(
-		"postgresql://%s:%s@%s:%s/%s?sslmode=disable&search_path=public",
+		"postgresql://%s:%s@%s:%s/%s?sslmode=disable&search_path=%s",
 		creds.user,
 		creds.password,
 		creds.host,
 		creds.port,
 		creds.database,
+		url.QueryEscape(searchPath(d.schema)),
 	)
 	m, err := migrate.New(os.Getenv("MIGRATIONS_PATH"), connStrMigration)
 	if err != nil {
@@ -109,6 +141,18 @@ func (d *Database) NukeDatabase() error {
 	return nil
 }
 
+// DropSchema drops the schema of a scratch database and everything in it.
+func (d *Database) DropSchema() error {
+	if d.schema == "public" {
+		return fmt.Errorf("refusing to drop the public schema")
+	}
+	_, err := d.pool.Exec(context.Background(), "DROP SCHEMA IF EXISTS "+pgx.Identifier{d.schema}.Sanitize()+" CASCADE")
+	if err != nil {
+		return fmt.Errorf("failed to drop schema %s: %v", d.schema, err)
+	}
+	return nil
+}
+
 func (d *Database) HealthCheck() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -12,6 +12,61 @@ import (
 	"github.com/google/uuid"
 )
 
+const findImagePools = `-- name: FindImagePools :many
+SELECT
+  tu.pool_min,
+  tu.pool_max,
+  tu.pool_language_timeout,
+  tu.pool_container_timeout,
+  COALESCE(tu.id = (
+    SELECT id FROM tutorials
+    WHERE unlock < NOW ()
+    ORDER BY unlock DESC, version DESC
+    LIMIT 1
+  ), false)::boolean AS is_current
+FROM
+  tutorials tu
+WHERE
+  EXISTS (
+    SELECT 1 FROM sheets s
+    WHERE s.tutorial_id = tu.id AND s.docker_image = $1
+  )
+`
+
+type FindImagePoolsRow struct {
+	PoolMin              int32
+	PoolMax              int32
+	PoolLanguageTimeout  int64
+	PoolContainerTimeout int64
+	IsCurrent            bool
+}
+
+func (q *Queries) FindImagePools(ctx context.Context, dockerImage string) ([]FindImagePoolsRow, error) {
+	rows, err := q.db.Query(ctx, findImagePools, dockerImage)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindImagePoolsRow
+	for rows.Next() {
+		var i FindImagePoolsRow
+		if err := rows.Scan(
+			&i.PoolMin,
+			&i.PoolMax,
+			&i.PoolLanguageTimeout,
+			&i.PoolContainerTimeout,
+			&i.IsCurrent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const findLastTutorialSheet = `-- name: FindLastTutorialSheet :one
 SELECT
   tu.title,
@@ -21,7 +76,11 @@ SELECT
   s.guide_content,
   s.exercise_content,
   s.page,
-  s.submission_content,
+  (SELECT array_agg(f.name ORDER BY f.position) FROM files f WHERE f.sheet_id = s.id AND f.editable)::text[] AS submission_names,
+  (SELECT array_agg(f.starter_content ORDER BY f.position) FROM files f WHERE f.sheet_id = s.id AND f.editable)::text[] AS submission_contents,
+  (SELECT array_agg(f.name ORDER BY f.name) FROM files f WHERE f.sheet_id = s.id AND f.test_set = 'visible')::text[] AS visible_test_names,
+  (SELECT array_agg(f.content ORDER BY f.name) FROM files f WHERE f.sheet_id = s.id AND f.test_set = 'visible')::text[] AS visible_test_contents,
+  (tu.run_command <> '')::boolean AS scratchpad,
   (SELECT COUNT(page) FROM sheets sh WHERE sh.tutorial_id = tu.id) as total_pages
 FROM
   tutorials tu
@@ -37,15 +96,19 @@ LIMIT
 `
 
 type FindLastTutorialSheetRow struct {
-	Title             string
-	TutorialID        uuid.UUID
-	CodeEditor        string
-	SheetID           uuid.UUID
-	GuideContent      string
-	ExerciseContent   string
-	Page              int32
-	SubmissionContent string
-	TotalPages        int64
+	Title               string
+	TutorialID          uuid.UUID
+	CodeEditor          string
+	SheetID             uuid.UUID
+	GuideContent        string
+	ExerciseContent     string
+	Page                int32
+	SubmissionNames     []string
+	SubmissionContents  []string
+	VisibleTestNames    []string
+	VisibleTestContents []string
+	Scratchpad          bool
+	TotalPages          int64
 }
 
 func (q *Queries) FindLastTutorialSheet(ctx context.Context, page int32) (FindLastTutorialSheetRow, error) {
@@ -59,7 +122,11 @@ func (q *Queries) FindLastTutorialSheet(ctx context.Context, page int32) (FindLa
 		&i.GuideContent,
 		&i.ExerciseContent,
 		&i.Page,
-		&i.SubmissionContent,
+		&i.SubmissionNames,
+		&i.SubmissionContents,
+		&i.VisibleTestNames,
+		&i.VisibleTestContents,
+		&i.Scratchpad,
 		&i.TotalPages,
 	)
 	return i, err
@@ -74,7 +141,11 @@ SELECT
   s.guide_content,
   s.exercise_content,
   s.page,
-  s.submission_content,
+  (SELECT array_agg(f.name ORDER BY f.position) FROM files f WHERE f.sheet_id = s.id AND f.editable)::text[] AS submission_names,
+  (SELECT array_agg(f.starter_content ORDER BY f.position) FROM files f WHERE f.sheet_id = s.id AND f.editable)::text[] AS submission_contents,
+  (SELECT array_agg(f.name ORDER BY f.name) FROM files f WHERE f.sheet_id = s.id AND f.test_set = 'visible')::text[] AS visible_test_names,
+  (SELECT array_agg(f.content ORDER BY f.name) FROM files f WHERE f.sheet_id = s.id AND f.test_set = 'visible')::text[] AS visible_test_contents,
+  (tu.run_command <> '')::boolean AS scratchpad,
   (SELECT COUNT(page) FROM sheets sh WHERE sh.tutorial_id = tu.id) as total_pages
 FROM
   tutorials tu
@@ -96,15 +167,19 @@ type FindSpecificTutorialSheetParams struct {
 }
 
 type FindSpecificTutorialSheetRow struct {
-	Title             string
-	TutorialID        uuid.UUID
-	CodeEditor        string
-	SheetID           uuid.UUID
-	GuideContent      string
-	ExerciseContent   string
-	Page              int32
-	SubmissionContent string
-	TotalPages        int64
+	Title               string
+	TutorialID          uuid.UUID
+	CodeEditor          string
+	SheetID             uuid.UUID
+	GuideContent        string
+	ExerciseContent     string
+	Page                int32
+	SubmissionNames     []string
+	SubmissionContents  []string
+	VisibleTestNames    []string
+	VisibleTestContents []string
+	Scratchpad          bool
+	TotalPages          int64
 }
 
 func (q *Queries) FindSpecificTutorialSheet(ctx context.Context, arg FindSpecificTutorialSheetParams) (FindSpecificTutorialSheetRow, error) {
@@ -118,7 +193,11 @@ func (q *Queries) FindSpecificTutorialSheet(ctx context.Context, arg FindSpecifi
 		&i.GuideContent,
 		&i.ExerciseContent,
 		&i.Page,
-		&i.SubmissionContent,
+		&i.SubmissionNames,
+		&i.SubmissionContents,
+		&i.VisibleTestNames,
+		&i.VisibleTestContents,
+		&i.Scratchpad,
 		&i.TotalPages,
 	)
 	return i, err
@@ -127,25 +206,78 @@ func (q *Queries) FindSpecificTutorialSheet(ctx context.Context, arg FindSpecifi
 const findSubmissionData = `-- name: FindSubmissionData :one
 SELECT
   s.docker_image,
+  s.image_digest,
   s.command,
+  s.parser,
+  s.memory,
+  s.cpus,
+  s.timeout,
+  s.pids_limit,
+  s.output_limit,
   s.submission_name,
+  tu.run_command AS tutorial_run_command,
+  tu.sandbox_user,
+  tu.sandbox_writable,
+  tu.sandbox_workspace_size,
+  tu.sandbox_nofile,
+  tu.sandbox_nproc,
+  tu.sandbox_seccomp,
+  tu.cache_paths,
+  tu.cache_envs,
+  tu.pool_mode,
+  s.grading,
+  s.run_command,
+  s.compare,
+  s.tolerance,
+  (SELECT array_agg(c.input ORDER BY c.position) FROM cases c WHERE c.sheet_id = s.id)::text[] AS cases_input,
+  (SELECT array_agg(c.expected ORDER BY c.position) FROM cases c WHERE c.sheet_id = s.id)::text[] AS cases_expected,
   array_agg(f.name)::text[] AS files_name,
-  array_agg(f.content)::text[] AS files_content
+  array_agg(f.content)::text[] AS files_content,
+  array_agg(f.editable)::boolean[] AS files_editable,
+  array_agg(f.starter_content)::text[] AS files_starter,
+  array_agg(f.test_set)::text[] AS files_test_set
 FROM
   sheets s
+  JOIN tutorials tu ON tu.id = s.tutorial_id
   JOIN files f ON f.sheet_id = s.id
 WHERE
   s.id = $1
 GROUP BY
-  s.id, s.docker_image, s.command, s.submission_name
+  s.id, tu.id, s.docker_image, s.command, s.parser, s.submission_name
 `
 
 type FindSubmissionDataRow struct {
-	DockerImage    string
-	Command        string
-	SubmissionName string
-	FilesName      []string
-	FilesContent   []string
+	DockerImage          string
+	ImageDigest          string
+	Command              string
+	Parser               string
+	Memory               int64
+	Cpus                 float64
+	Timeout              int64
+	PidsLimit            int64
+	OutputLimit          int64
+	SubmissionName       string
+	TutorialRunCommand   string
+	SandboxUser          string
+	SandboxWritable      bool
+	SandboxWorkspaceSize int64
+	SandboxNofile        int64
+	SandboxNproc         int64
+	SandboxSeccomp       string
+	CachePaths           []string
+	CacheEnvs            []string
+	PoolMode             string
+	Grading              string
+	RunCommand           string
+	Compare              string
+	Tolerance            float64
+	CasesInput           []string
+	CasesExpected        []string
+	FilesName            []string
+	FilesContent         []string
+	FilesEditable        []bool
+	FilesStarter         []string
+	FilesTestSet         []string
 }
 
 func (q *Queries) FindSubmissionData(ctx context.Context, sheetID uuid.UUID) (FindSubmissionDataRow, error) {
@@ -153,34 +285,143 @@ func (q *Queries) FindSubmissionData(ctx context.Context, sheetID uuid.UUID) (Fi
 	var i FindSubmissionDataRow
 	err := row.Scan(
 		&i.DockerImage,
+		&i.ImageDigest,
 		&i.Command,
+		&i.Parser,
+		&i.Memory,
+		&i.Cpus,
+		&i.Timeout,
+		&i.PidsLimit,
+		&i.OutputLimit,
 		&i.SubmissionName,
+		&i.TutorialRunCommand,
+		&i.SandboxUser,
+		&i.SandboxWritable,
+		&i.SandboxWorkspaceSize,
+		&i.SandboxNofile,
+		&i.SandboxNproc,
+		&i.SandboxSeccomp,
+		&i.CachePaths,
+		&i.CacheEnvs,
+		&i.PoolMode,
+		&i.Grading,
+		&i.RunCommand,
+		&i.Compare,
+		&i.Tolerance,
+		&i.CasesInput,
+		&i.CasesExpected,
 		&i.FilesName,
 		&i.FilesContent,
+		&i.FilesEditable,
+		&i.FilesStarter,
+		&i.FilesTestSet,
 	)
 	return i, err
 }
 
+const insertCases = `-- name: InsertCases :exec
+INSERT INTO cases (sheet_id, position, input, expected)
+SELECT
+  $1,
+  unnest($2::integer[]),
+  unnest($3::text[]),
+  unnest($4::text[])
+`
+
+type InsertCasesParams struct {
+	SheetID   uuid.UUID
+	Positions []int32
+	Inputs    []string
+	Expected  []string
+}
+
+func (q *Queries) InsertCases(ctx context.Context, arg InsertCasesParams) error {
+	_, err := q.db.Exec(ctx, insertCases,
+		arg.SheetID,
+		arg.Positions,
+		arg.Inputs,
+		arg.Expected,
+	)
+	return err
+}
+
 const insertFiles = `-- name: InsertFiles :exec
-INSERT INTO files (name, content, sheet_id)
-SELECT unnest($1::text[]), unnest($2::text[]), $3
+INSERT INTO files (name, content, sheet_id, editable, starter_content, position, test_set)
+SELECT
+  unnest($1::text[]),
+  unnest($2::text[]),
+  $3,
+  unnest($4::boolean[]),
+  unnest($5::text[]),
+  unnest($6::integer[]),
+  unnest($7::text[])
 `
 
 type InsertFilesParams struct {
-	Names    []string
-	Contents []string
-	SheetID  uuid.UUID
+	Names           []string
+	Contents        []string
+	SheetID         uuid.UUID
+	Editables       []bool
+	StartersContent []string
+	Positions       []int32
+	TestSets        []string
 }
 
 func (q *Queries) InsertFiles(ctx context.Context, arg InsertFilesParams) error {
-	_, err := q.db.Exec(ctx, insertFiles, arg.Names, arg.Contents, arg.SheetID)
+	_, err := q.db.Exec(ctx, insertFiles,
+		arg.Names,
+		arg.Contents,
+		arg.SheetID,
+		arg.Editables,
+		arg.StartersContent,
+		arg.Positions,
+		arg.TestSets,
+	)
 	return err
 }
 
 const insertTutorial = `-- name: InsertTutorial :many
 WITH tutorial AS (
-  INSERT INTO tutorials (title, code_editor, version, unlock)
-  VALUES ($1, $2, $3, $4)
+  INSERT INTO tutorials (
+    title,
+    code_editor,
+    version,
+    unlock,
+    pool_min,
+    pool_max,
+    pool_language_timeout,
+    pool_container_timeout,
+    run_command,
+    sandbox_user,
+    sandbox_writable,
+    sandbox_workspace_size,
+    sandbox_nofile,
+    sandbox_nproc,
+    sandbox_seccomp,
+    cache_paths,
+    cache_envs,
+    pool_mode
+  )
+  VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11,
+    $12,
+    $13,
+    $14,
+    $15,
+    $16::text[],
+    $17::text[],
+    $18
+  )
   RETURNING id
 ), sheet AS (
   INSERT INTO sheets (
@@ -192,36 +433,83 @@ WITH tutorial AS (
     submission_content,
     correction_content,
     docker_image,
-    command
+    image_digest,
+    command,
+    parser,
+    memory,
+    cpus,
+    timeout,
+    pids_limit,
+    output_limit,
+    grading,
+    run_command,
+    compare,
+    tolerance
   )
   SELECT
     (SELECT id FROM tutorial),
-    unnest($5::integer[]),
-    unnest($6::text[]),
-    unnest($7::text[]),
-    unnest($8::text[]),
-    unnest($9::text[]),
-    unnest($10::text[]),
-    unnest($11::text[]),
-    unnest($12::text[])
+    unnest($19::integer[]),
+    unnest($20::text[]),
+    unnest($21::text[]),
+    unnest($22::text[]),
+    unnest($23::text[]),
+    unnest($24::text[]),
+    unnest($25::text[]),
+    unnest($26::text[]),
+    unnest($27::text[]),
+    unnest($28::text[]),
+    unnest($29::bigint[]),
+    unnest($30::float8[]),
+    unnest($31::bigint[]),
+    unnest($32::bigint[]),
+    unnest($33::bigint[]),
+    unnest($34::text[]),
+    unnest($35::text[]),
+    unnest($36::text[]),
+    unnest($37::float8[])
   RETURNING id
 )
 SELECT id FROM sheet
 `
 
 type InsertTutorialParams struct {
-	Title              string
-	CodeEditor         string
-	Version            int32
-	Unlock             time.Time
-	Pages              []int32
-	GuidesContent      []string
-	ExercisesContent   []string
-	SubmissionsName    []string
-	SubmissionsContent []string
-	CorrectionContent  []string
-	DockerImages       []string
-	Commands           []string
+	Title                string
+	CodeEditor           string
+	Version              int32
+	Unlock               time.Time
+	PoolMin              int32
+	PoolMax              int32
+	PoolLanguageTimeout  int64
+	PoolContainerTimeout int64
+	RunCommand           string
+	SandboxUser          string
+	SandboxWritable      bool
+	SandboxWorkspaceSize int64
+	SandboxNofile        int64
+	SandboxNproc         int64
+	SandboxSeccomp       string
+	CachePaths           []string
+	CacheEnvs            []string
+	PoolMode             string
+	Pages                []int32
+	GuidesContent        []string
+	ExercisesContent     []string
+	SubmissionsName      []string
+	SubmissionsContent   []string
+	CorrectionContent    []string
+	DockerImages         []string
+	ImageDigests         []string
+	Commands             []string
+	Parsers              []string
+	Memories             []int64
+	Cpus                 []float64
+	Timeouts             []int64
+	PidsLimits           []int64
+	OutputLimits         []int64
+	Gradings             []string
+	RunCommands          []string
+	Compares             []string
+	Tolerances           []float64
 }
 
 func (q *Queries) InsertTutorial(ctx context.Context, arg InsertTutorialParams) ([]uuid.UUID, error) {
@@ -230,6 +518,20 @@ func (q *Queries) InsertTutorial(ctx context.Context, arg InsertTutorialParams)
 		arg.CodeEditor,
 		arg.Version,
 		arg.Unlock,
+		arg.PoolMin,
+		arg.PoolMax,
+		arg.PoolLanguageTimeout,
+		arg.PoolContainerTimeout,
+		arg.RunCommand,
+		arg.SandboxUser,
+		arg.SandboxWritable,
+		arg.SandboxWorkspaceSize,
+		arg.SandboxNofile,
+		arg.SandboxNproc,
+		arg.SandboxSeccomp,
+		arg.CachePaths,
+		arg.CacheEnvs,
+		arg.PoolMode,
 		arg.Pages,
 		arg.GuidesContent,
 		arg.ExercisesContent,
@@ -237,7 +539,18 @@ func (q *Queries) InsertTutorial(ctx context.Context, arg InsertTutorialParams)
 		arg.SubmissionsContent,
 		arg.CorrectionContent,
 		arg.DockerImages,
+		arg.ImageDigests,
 		arg.Commands,
+		arg.Parsers,
+		arg.Memories,
+		arg.Cpus,
+		arg.Timeouts,
+		arg.PidsLimits,
+		arg.OutputLimits,
+		arg.Gradings,
+		arg.RunCommands,
+		arg.Compares,
+		arg.Tolerances,
 	)
 	if err != nil {
 		return nil, err
@@ -257,6 +570,50 @@ func (q *Queries) InsertTutorial(ctx context.Context, arg InsertTutorialParams)
 	return items, nil
 }
 
+const listSheetsToValidate = `-- name: ListSheetsToValidate :many
+SELECT
+  s.id,
+  s.page
+FROM
+  tutorials tu
+  JOIN sheets s ON s.tutorial_id = tu.id
+WHERE
+  tu.title = $1
+  AND tu.version = $2
+ORDER BY
+  s.page
+`
+
+type ListSheetsToValidateParams struct {
+	Title   string
+	Version int32
+}
+
+type ListSheetsToValidateRow struct {
+	ID   uuid.UUID
+	Page int32
+}
+
+func (q *Queries) ListSheetsToValidate(ctx context.Context, arg ListSheetsToValidateParams) ([]ListSheetsToValidateRow, error) {
+	rows, err := q.db.Query(ctx, listSheetsToValidate, arg.Title, arg.Version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSheetsToValidateRow
+	for rows.Next() {
+		var i ListSheetsToValidateRow
+		if err := rows.Scan(&i.ID, &i.Page); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listTutorials = `-- name: ListTutorials :many
 SELECT id, title
 FROM (
@@ -11,11 +11,23 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type Case struct {
+	ID       uuid.UUID
+	SheetID  uuid.UUID
+	Position int32
+	Input    string
+	Expected string
+}
+
 type File struct {
-	ID      uuid.UUID
-	Name    string
-	Content string
-	SheetID uuid.UUID
+	ID             uuid.UUID
+	Name           string
+	Content        string
+	SheetID        uuid.UUID
+	Editable       bool
+	StarterContent string
+	Position       int32
+	TestSet        string
 }
 
 type Sheet struct {
@@ -29,14 +41,39 @@ type Sheet struct {
 	CorrectionContent string
 	DockerImage       string
 	Command           string
+	Parser            string
+	Memory            int64
+	Cpus              float64
+	Timeout           int64
+	PidsLimit         int64
+	OutputLimit       int64
+	ImageDigest       string
+	Grading           string
+	RunCommand        string
+	Compare           string
+	Tolerance         float64
 }
 
 type Tutorial struct {
-	ID         uuid.UUID
-	Title      string
-	CodeEditor string
-	Version    int32
-	Unlock     time.Time
-	CreatedAt  pgtype.Timestamp
-	UpdatedAt  pgtype.Timestamp
+	ID                   uuid.UUID
+	Title                string
+	CodeEditor           string
+	Version              int32
+	Unlock               time.Time
+	CreatedAt            pgtype.Timestamp
+	UpdatedAt            pgtype.Timestamp
+	PoolMin              int32
+	PoolMax              int32
+	PoolLanguageTimeout  int64
+	PoolContainerTimeout int64
+	RunCommand           string
+	SandboxUser          string
+	SandboxWritable      bool
+	SandboxWorkspaceSize int64
+	SandboxNofile        int64
+	SandboxNproc         int64
+	SandboxSeccomp       string
+	CachePaths           []string
+	CacheEnvs            []string
+	PoolMode             string
 }
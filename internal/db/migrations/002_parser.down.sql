@@ -0,0 +1 @@
+ALTER TABLE sheets DROP COLUMN parser;
@@ -0,0 +1 @@
+ALTER TABLE sheets ADD COLUMN parser TEXT NOT NULL DEFAULT ''; -- Parser of the test command output
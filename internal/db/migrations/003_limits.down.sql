@@ -0,0 +1,6 @@
+ALTER TABLE sheets
+  DROP COLUMN memory,
+  DROP COLUMN cpus,
+  DROP COLUMN timeout,
+  DROP COLUMN pids_limit,
+  DROP COLUMN output_limit;
@@ -0,0 +1,7 @@
+-- Resources of the container, 0 means the default of the server
+ALTER TABLE sheets
+  ADD COLUMN memory BIGINT NOT NULL DEFAULT 0, -- in bytes
+  ADD COLUMN cpus DOUBLE PRECISION NOT NULL DEFAULT 0,
+  ADD COLUMN timeout BIGINT NOT NULL DEFAULT 0, -- in milliseconds
+  ADD COLUMN pids_limit BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN output_limit BIGINT NOT NULL DEFAULT 0; -- in bytes
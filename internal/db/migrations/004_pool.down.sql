@@ -0,0 +1,5 @@
+ALTER TABLE tutorials
+  DROP COLUMN pool_min,
+  DROP COLUMN pool_max,
+  DROP COLUMN pool_language_timeout,
+  DROP COLUMN pool_container_timeout;
@@ -0,0 +1,6 @@
+-- Pool of the containers of the tutorial, see [pool] in meta.toml
+ALTER TABLE tutorials
+  ADD COLUMN pool_min INTEGER NOT NULL DEFAULT -1, -- -1 means the default of the server
+  ADD COLUMN pool_max INTEGER NOT NULL DEFAULT 0, -- 0 means the default of the server
+  ADD COLUMN pool_language_timeout BIGINT NOT NULL DEFAULT 0, -- in milliseconds
+  ADD COLUMN pool_container_timeout BIGINT NOT NULL DEFAULT 0; -- in milliseconds
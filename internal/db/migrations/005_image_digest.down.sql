@@ -0,0 +1,2 @@
+ALTER TABLE sheets
+  DROP COLUMN image_digest;
@@ -0,0 +1,3 @@
+-- Id of the image resolved when importing the tutorial, empty to use docker_image as is
+ALTER TABLE sheets
+  ADD COLUMN image_digest TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+ALTER TABLE files
+  DROP COLUMN editable,
+  DROP COLUMN starter_content,
+  DROP COLUMN position;
@@ -0,0 +1,16 @@
+-- Files of the sheet the learner edits, in the order of the editor tabs.
+-- The submission columns of sheets keep describing the main one.
+ALTER TABLE files
+  ADD COLUMN editable BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN starter_content TEXT NOT NULL DEFAULT '', -- content shown before any edit
+  ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+UPDATE files f
+SET
+  editable = true,
+  starter_content = s.submission_content
+FROM
+  sheets s
+WHERE
+  f.sheet_id = s.id
+  AND f.name = s.submission_name;
@@ -0,0 +1,4 @@
+-- Test files of the sheet: 'visible' ones are shown and run with the Run
+-- button, 'hidden' ones only on Submit. Other files are always copied.
+ALTER TABLE files
+  ADD COLUMN test_set TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+DROP TABLE cases;
+
+ALTER TABLE sheets
+  DROP COLUMN grading,
+  DROP COLUMN run_command,
+  DROP COLUMN compare,
+  DROP COLUMN tolerance;
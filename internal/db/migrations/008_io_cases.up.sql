@@ -0,0 +1,16 @@
+-- Sheets graded on the output of the submission for each case rather than a test command.
+-- For them, command builds the submission and run_command runs it once per case.
+ALTER TABLE sheets
+  ADD COLUMN grading TEXT NOT NULL DEFAULT 'test', -- 'test' or 'io'
+  ADD COLUMN run_command TEXT NOT NULL DEFAULT '',
+  ADD COLUMN compare TEXT NOT NULL DEFAULT '', -- exact, trimmed, regex or float
+  ADD COLUMN tolerance DOUBLE PRECISION NOT NULL DEFAULT 0; -- for float
+
+CREATE TABLE cases (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+  sheet_id UUID NOT NULL REFERENCES sheets (id),
+  position INTEGER NOT NULL,
+  input TEXT NOT NULL,
+  expected TEXT NOT NULL,
+  UNIQUE (sheet_id, position)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE tutorials
+  DROP COLUMN run_command;
@@ -0,0 +1,3 @@
+-- Command running the code of the scratchpad, empty when the tutorial has none
+ALTER TABLE tutorials
+  ADD COLUMN run_command TEXT NOT NULL DEFAULT '';
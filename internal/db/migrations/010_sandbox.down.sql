@@ -0,0 +1,7 @@
+ALTER TABLE tutorials
+  DROP COLUMN sandbox_user,
+  DROP COLUMN sandbox_writable,
+  DROP COLUMN sandbox_workspace_size,
+  DROP COLUMN sandbox_nofile,
+  DROP COLUMN sandbox_nproc,
+  DROP COLUMN sandbox_seccomp;
@@ -0,0 +1,9 @@
+-- Hardening of the containers of the tutorial, see [sandbox] in meta.toml.
+-- Empty and 0 values mean the default of the server
+ALTER TABLE tutorials
+  ADD COLUMN sandbox_user TEXT NOT NULL DEFAULT '', -- "uid[:gid]"
+  ADD COLUMN sandbox_writable BOOLEAN NOT NULL DEFAULT false, -- writable root filesystem
+  ADD COLUMN sandbox_workspace_size BIGINT NOT NULL DEFAULT 0, -- in bytes
+  ADD COLUMN sandbox_nofile BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN sandbox_nproc BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN sandbox_seccomp TEXT NOT NULL DEFAULT ''; -- JSON profile
@@ -0,0 +1,3 @@
+ALTER TABLE tutorials
+  DROP COLUMN cache_paths,
+  DROP COLUMN cache_envs;
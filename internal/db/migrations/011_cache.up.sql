@@ -0,0 +1,5 @@
+-- Build caches baked in the image of the tutorial, see [[cache]] in meta.toml.
+-- The arrays are parallel, one entry per cache
+ALTER TABLE tutorials
+  ADD COLUMN cache_paths TEXT[] NOT NULL DEFAULT '{}',
+  ADD COLUMN cache_envs TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,4 @@
+-- How the runs use the containers of the tutorial, see mode in [pool] of
+-- meta.toml. Empty means the containers are started for each run
+ALTER TABLE tutorials
+  ADD COLUMN pool_mode TEXT NOT NULL DEFAULT '';
@@ -1,7 +1,45 @@
 -- name: InsertTutorial :many
 WITH tutorial AS (
-  INSERT INTO tutorials (title, code_editor, version, unlock)
-  VALUES (@title, @code_editor, @version, @unlock)
+  INSERT INTO tutorials (
+    title,
+    code_editor,
+    version,
+    unlock,
+    pool_min,
+    pool_max,
+    pool_language_timeout,
+    pool_container_timeout,
+    run_command,
+    sandbox_user,
+    sandbox_writable,
+    sandbox_workspace_size,
+    sandbox_nofile,
+    sandbox_nproc,
+    sandbox_seccomp,
+    cache_paths,
+    cache_envs,
+    pool_mode
+  )
+  VALUES (
+    @title,
+    @code_editor,
+    @version,
+    @unlock,
+    @pool_min,
+    @pool_max,
+    @pool_language_timeout,
+    @pool_container_timeout,
+    @run_command,
+    @sandbox_user,
+    @sandbox_writable,
+    @sandbox_workspace_size,
+    @sandbox_nofile,
+    @sandbox_nproc,
+    @sandbox_seccomp,
+    @cache_paths::text[],
+    @cache_envs::text[],
+    @pool_mode
+  )
   RETURNING id
 ), sheet AS (
   INSERT INTO sheets (
@@ -13,7 +51,18 @@ WITH tutorial AS (
     submission_content,
     correction_content,
     docker_image,
-    command
+    image_digest,
+    command,
+    parser,
+    memory,
+    cpus,
+    timeout,
+    pids_limit,
+    output_limit,
+    grading,
+    run_command,
+    compare,
+    tolerance
   )
   SELECT
     (SELECT id FROM tutorial),
@@ -24,14 +73,40 @@ WITH tutorial AS (
     unnest(@submissions_content::text[]),
     unnest(@correction_content::text[]),
     unnest(@docker_images::text[]),
-    unnest(@commands::text[])
+    unnest(@image_digests::text[]),
+    unnest(@commands::text[]),
+    unnest(@parsers::text[]),
+    unnest(@memories::bigint[]),
+    unnest(@cpus::float8[]),
+    unnest(@timeouts::bigint[]),
+    unnest(@pids_limits::bigint[]),
+    unnest(@output_limits::bigint[]),
+    unnest(@gradings::text[]),
+    unnest(@run_commands::text[]),
+    unnest(@compares::text[]),
+    unnest(@tolerances::float8[])
   RETURNING id
 )
 SELECT id FROM sheet;
 
+-- name: InsertCases :exec
+INSERT INTO cases (sheet_id, position, input, expected)
+SELECT
+  @sheet_id,
+  unnest(@positions::integer[]),
+  unnest(@inputs::text[]),
+  unnest(@expected::text[]);
+
 -- name: InsertFiles :exec
-INSERT INTO files (name, content, sheet_id)
-SELECT unnest(@names::text[]), unnest(@contents::text[]), @sheet_id;
+INSERT INTO files (name, content, sheet_id, editable, starter_content, position, test_set)
+SELECT
+  unnest(@names::text[]),
+  unnest(@contents::text[]),
+  @sheet_id,
+  unnest(@editables::boolean[]),
+  unnest(@starters_content::text[]),
+  unnest(@positions::integer[]),
+  unnest(@test_sets::text[]);
 
 -- name: FindLastTutorialSheet :one
 SELECT
@@ -42,7 +117,11 @@ SELECT
   s.guide_content,
   s.exercise_content,
   s.page,
-  s.submission_content,
+  (SELECT array_agg(f.name ORDER BY f.position) FROM files f WHERE f.sheet_id = s.id AND f.editable)::text[] AS submission_names,
+  (SELECT array_agg(f.starter_content ORDER BY f.position) FROM files f WHERE f.sheet_id = s.id AND f.editable)::text[] AS submission_contents,
+  (SELECT array_agg(f.name ORDER BY f.name) FROM files f WHERE f.sheet_id = s.id AND f.test_set = 'visible')::text[] AS visible_test_names,
+  (SELECT array_agg(f.content ORDER BY f.name) FROM files f WHERE f.sheet_id = s.id AND f.test_set = 'visible')::text[] AS visible_test_contents,
+  (tu.run_command <> '')::boolean AS scratchpad,
   (SELECT COUNT(page) FROM sheets sh WHERE sh.tutorial_id = tu.id) as total_pages
 FROM
   tutorials tu
@@ -65,7 +144,11 @@ SELECT
   s.guide_content,
   s.exercise_content,
   s.page,
-  s.submission_content,
+  (SELECT array_agg(f.name ORDER BY f.position) FROM files f WHERE f.sheet_id = s.id AND f.editable)::text[] AS submission_names,
+  (SELECT array_agg(f.starter_content ORDER BY f.position) FROM files f WHERE f.sheet_id = s.id AND f.editable)::text[] AS submission_contents,
+  (SELECT array_agg(f.name ORDER BY f.name) FROM files f WHERE f.sheet_id = s.id AND f.test_set = 'visible')::text[] AS visible_test_names,
+  (SELECT array_agg(f.content ORDER BY f.name) FROM files f WHERE f.sheet_id = s.id AND f.test_set = 'visible')::text[] AS visible_test_contents,
+  (tu.run_command <> '')::boolean AS scratchpad,
   (SELECT COUNT(page) FROM sheets sh WHERE sh.tutorial_id = tu.id) as total_pages
 FROM
   tutorials tu
@@ -83,17 +166,44 @@ LIMIT
 -- name: FindSubmissionData :one
 SELECT
   s.docker_image,
+  s.image_digest,
   s.command,
+  s.parser,
+  s.memory,
+  s.cpus,
+  s.timeout,
+  s.pids_limit,
+  s.output_limit,
   s.submission_name,
+  tu.run_command AS tutorial_run_command,
+  tu.sandbox_user,
+  tu.sandbox_writable,
+  tu.sandbox_workspace_size,
+  tu.sandbox_nofile,
+  tu.sandbox_nproc,
+  tu.sandbox_seccomp,
+  tu.cache_paths,
+  tu.cache_envs,
+  tu.pool_mode,
+  s.grading,
+  s.run_command,
+  s.compare,
+  s.tolerance,
+  (SELECT array_agg(c.input ORDER BY c.position) FROM cases c WHERE c.sheet_id = s.id)::text[] AS cases_input,
+  (SELECT array_agg(c.expected ORDER BY c.position) FROM cases c WHERE c.sheet_id = s.id)::text[] AS cases_expected,
   array_agg(f.name)::text[] AS files_name,
-  array_agg(f.content)::text[] AS files_content
+  array_agg(f.content)::text[] AS files_content,
+  array_agg(f.editable)::boolean[] AS files_editable,
+  array_agg(f.starter_content)::text[] AS files_starter,
+  array_agg(f.test_set)::text[] AS files_test_set
 FROM
   sheets s
+  JOIN tutorials tu ON tu.id = s.tutorial_id
   JOIN files f ON f.sheet_id = s.id
 WHERE
   s.id = @sheet_id
 GROUP BY
-  s.id, s.docker_image, s.command, s.submission_name;
+  s.id, tu.id, s.docker_image, s.command, s.parser, s.submission_name;
 
 -- name: ListTutorials :many
 SELECT id, title
@@ -106,3 +216,36 @@ FROM (
   WHERE unlock < NOW ()
 ) t
 WHERE rn = 1;
+
+-- name: FindImagePools :many
+SELECT
+  tu.pool_min,
+  tu.pool_max,
+  tu.pool_language_timeout,
+  tu.pool_container_timeout,
+  COALESCE(tu.id = (
+    SELECT id FROM tutorials
+    WHERE unlock < NOW ()
+    ORDER BY unlock DESC, version DESC
+    LIMIT 1
+  ), false)::boolean AS is_current
+FROM
+  tutorials tu
+WHERE
+  EXISTS (
+    SELECT 1 FROM sheets s
+    WHERE s.tutorial_id = tu.id AND s.docker_image = @docker_image
+  );
+
+-- name: ListSheetsToValidate :many
+SELECT
+  s.id,
+  s.page
+FROM
+  tutorials tu
+  JOIN sheets s ON s.tutorial_id = tu.id
+WHERE
+  tu.title = @title
+  AND tu.version = @version
+ORDER BY
+  s.page;
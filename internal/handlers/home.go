@@ -22,7 +22,9 @@ func (app *App) HomeHandler(w http.ResponseWriter, r *http.Request) {
 		tutorial.CodeEditor,
 		tutorial.GuideContent,
 		tutorial.ExerciseContent,
-		tutorial.SubmissionContent,
+		models.NewFilesTempl(tutorial.SubmissionNames, tutorial.SubmissionContents),
+		models.NewFilesTempl(tutorial.VisibleTestNames, tutorial.VisibleTestContents),
+		tutorial.Scratchpad,
 		1,
 		int(tutorial.TotalPages),
 		true,
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"nexzap/internal/metrics"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "nexzap_http_requests_total",
+		Help: "HTTP requests, by handler and status code.",
+	}, []string{"handler", "code"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "nexzap_http_request_duration_seconds",
+		Help:    "Duration of the HTTP requests, by handler.",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"handler"})
+)
+
+// instrument records the requests served by the handler under the given name.
+func instrument(name string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(recorder, r)
+		httpRequests.WithLabelValues(name, strconv.Itoa(recorder.status)).Inc()
+		httpDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	}
+}
+
+// statusRecorder keeps the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps the streaming handlers working through the recorder.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
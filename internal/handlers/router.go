@@ -42,8 +42,9 @@ func SetupRouter(app *App) {
 		http.ServeFile(w, r, "static/images/favicon.ico")
 	})
 
-	http.HandleFunc("/", app.HomeHandler)
-	http.HandleFunc("/sheet", app.SheetHandler)
-	http.HandleFunc("/submit", app.SubmitHandler)
-
+	http.HandleFunc("/", instrument("/", app.HomeHandler))
+	http.HandleFunc("/sheet", instrument("/sheet", app.SheetHandler))
+	http.HandleFunc("/submit", instrument("/submit", app.SubmitHandler))
+	http.HandleFunc("/submit/stream", instrument("/submit/stream", app.SubmitStreamHandler))
+	http.HandleFunc("/run", instrument("/run", app.RunHandler))
 }
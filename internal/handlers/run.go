@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"nexzap/internal/services/container"
+)
+
+// runResponse is the result of code run in the scratchpad, sent as JSON.
+type runResponse struct {
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	StatusCode int    `json:"statusCode"`
+	TimedOut   bool   `json:"timedOut,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	// Position in the queue when the server is too busy to run the code
+	Position int `json:"position,omitempty"`
+}
+
+// RunHandler runs the code of the editor in the scratchpad of the tutorial,
+// with the optional "stdin" parameter as input. Nothing is graded.
+func (app *App) RunHandler(w http.ResponseWriter, r *http.Request) {
+	sub, sheetUUID, err := parseSubmission(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := runResponse{}
+	defer func() {
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+	}()
+
+	submissionData, err := app.Database.GetRepository().FindSubmissionData(r.Context(), sheetUUID)
+	if err != nil {
+		response.Stderr = "Failed to retrieve submission data"
+		response.StatusCode = 520
+		return
+	}
+
+	result, err := app.ExerciseService.RunScratch(
+		submissionData,
+		sub.filesFor(submissionData),
+		r.FormValue("stdin"),
+		container.Waiter{Client: clientID(r)},
+	)
+	if err != nil {
+		// Reported like the errors of a submission
+		var failure submitResponse
+		setRunError(&failure, err)
+		response.Stderr = failure.Output
+		response.StatusCode = failure.StatusCode
+		response.Position = failure.Position
+		return
+	}
+	response.Stdout = app.SheetService.Sanitize(result.Stdout)
+	response.Stderr = app.SheetService.Sanitize(result.Stderr)
+	response.StatusCode = int(result.StatusCode)
+	response.TimedOut = result.TimedOut
+	response.Truncated = result.Truncated
+}
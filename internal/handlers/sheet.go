@@ -38,7 +38,9 @@ func (app *App) SheetHandler(w http.ResponseWriter, r *http.Request) {
 			tutorial.CodeEditor,
 			tutorial.GuideContent,
 			tutorial.ExerciseContent,
-			tutorial.SubmissionContent,
+			models.NewFilesTempl(tutorial.SubmissionNames, tutorial.SubmissionContents),
+			models.NewFilesTempl(tutorial.VisibleTestNames, tutorial.VisibleTestContents),
+			tutorial.Scratchpad,
 			pageIndex,
 			int(tutorial.TotalPages),
 			false,
@@ -55,7 +57,9 @@ func (app *App) SheetHandler(w http.ResponseWriter, r *http.Request) {
 			tutorial.CodeEditor,
 			tutorial.GuideContent,
 			tutorial.ExerciseContent,
-			tutorial.SubmissionContent,
+			models.NewFilesTempl(tutorial.SubmissionNames, tutorial.SubmissionContents),
+			models.NewFilesTempl(tutorial.VisibleTestNames, tutorial.VisibleTestContents),
+			tutorial.Scratchpad,
 			pageIndex,
 			int(tutorial.TotalPages),
 			true,
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// eventStream writes Server-Sent Events to the client, flushing each of them.
+type eventStream struct {
+	sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newEventStream(w http.ResponseWriter) (*eventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("Streaming not supported")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	return &eventStream{w: w, flusher: flusher}, nil
+}
+
+// send writes an event with its data encoded as JSON, so it always fits on a single line.
+func (s *eventStream) send(event string, data any) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, content); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// writer returns a writer sending each write as an event, after applying the filter.
+func (s *eventStream) writer(event string, filter func(string) string) *eventWriter {
+	return &eventWriter{stream: s, event: event, filter: filter}
+}
+
+type eventWriter struct {
+	stream *eventStream
+	event  string
+	filter func(string) string
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	if err := w.stream.send(w.event, w.filter(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
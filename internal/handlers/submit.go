@@ -3,40 +3,60 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
+	"nexzap/internal/services"
+	"nexzap/internal/services/container"
+	"nexzap/internal/services/report"
 
 	"github.com/google/uuid"
 )
 
+// submitResponse is the result of a submission, sent as JSON.
+type submitResponse struct {
+	Output     string            `json:"output"`
+	StatusCode int               `json:"statusCode"`
+	Tests      []report.TestCase `json:"tests"`
+	Stdout     string            `json:"stdout"`
+	Stderr     string            `json:"stderr"`
+	// Output as it was written, unless the parser rebuilt it
+	Chunks []container.Chunk `json:"chunks,omitempty"`
+	// The output went over the limit of the sheet and the run was stopped
+	Truncated bool `json:"truncated,omitempty"`
+	// Why the submission passed or not, unset when it was not run
+	Verdict services.Verdict `json:"verdict,omitempty"`
+	// Position in the queue when the server is too busy to run the submission
+	Position int `json:"position,omitempty"`
+	// Result of the hidden tests, only run on Submit
+	Hidden *hiddenResponse `json:"hidden,omitempty"`
+}
+
+// hiddenResponse is the result of the hidden tests of a submission. Only the
+// status of each test is sent, their output would reveal the tests.
+type hiddenResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Tests      []report.TestCase `json:"tests"`
+	Verdict    services.Verdict  `json:"verdict,omitempty"`
+}
+
+// statusBusy is the status code of a submission that could not get a container.
+const statusBusy = 503
+
 func (app *App) SubmitHandler(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Unable to parse form", http.StatusBadRequest)
-		return
-	}
-	payload := r.FormValue("payload")
-	if payload == "" {
-		http.Error(w, "No payload provided", http.StatusBadRequest)
-		return
-	}
-	sheetId := r.FormValue("sheet")
-	if sheetId == "" {
-		http.Error(w, "No sheet id provided", http.StatusBadRequest)
-		return
-	}
-	sheetUUID, err := uuid.Parse(sheetId)
+	sub, sheetUUID, err := parseSubmission(r)
 	if err != nil {
-		http.Error(w, "Invalid sheet id", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Respond with JSON containing the output and status code
 	w.Header().Set("Content-Type", "application/json")
-	response := struct {
-		Output     string `json:"output"`
-		StatusCode int    `json:"statusCode"`
-	}{
+	response := submitResponse{
 		Output:     "",
 		StatusCode: 0,
+		Tests:      []report.TestCase{},
 	}
 	defer func() {
 		if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -48,16 +68,212 @@ func (app *App) SubmitHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		response.Output = "Failed to retrieve submission data"
 		response.StatusCode = 520
+		response.Verdict = services.VerdictInternalError
+		return
+	}
+
+	waiter := container.Waiter{Client: clientID(r)}
+	output, status, err := app.ExerciseService.RunTest(
+		services.WithTests(submissionData, services.TestsVisible),
+		sub.filesFor(submissionData),
+		waiter,
+	)
+	if err != nil {
+		setRunError(&response, err)
+		return
+	}
+
+	app.fillResponse(&response, submissionData.Parser, output, status)
+	response.Hidden = app.runHidden(sub, submissionData, waiter)
+}
+
+// SubmitStreamHandler runs a submission and streams its output with Server-Sent Events.
+// Chunks of output are sent as "stdout" and "stderr" events, and the final
+// "done" event carries the same content as the response of SubmitHandler.
+// The files are POSTed in the body: they don't fit in a URL, and running the
+// code is not a GET.
+func (app *App) SubmitStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sub, sheetUUID, err := parseSubmission(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	events, err := newEventStream(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	output, status, err := app.ExerciseService.RunTest(submissionData, payload)
+	response := submitResponse{Tests: []report.TestCase{}}
+	defer func() {
+		if err := events.send("done", response); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	submissionData, err := app.Database.GetRepository().FindSubmissionData(r.Context(), sheetUUID)
 	if err != nil {
-		response.Output = "Failed to run the code"
+		response.Output = "Failed to retrieve submission data"
 		response.StatusCode = 520
+		response.Verdict = services.VerdictInternalError
+		return
+	}
+
+	// Keep the whole output to parse the tests once the run is done
+	var output container.OutputRecorder
+	waiter := container.Waiter{
+		Client: clientID(r),
+		OnQueue: func(position int) {
+			if err := events.send("queue", position); err != nil {
+				log.Println(err)
+			}
+		},
+	}
+	status, err := app.ExerciseService.RunTestStream(
+		services.WithTests(submissionData, services.TestsVisible),
+		sub.filesFor(submissionData),
+		waiter,
+		io.MultiWriter(output.Writer(container.STDOUT), events.writer(container.STDOUT, app.SheetService.Sanitize)),
+		io.MultiWriter(output.Writer(container.STDERR), events.writer(container.STDERR, app.SheetService.Sanitize)),
+	)
+	if err != nil {
+		setRunError(&response, err)
 		return
 	}
 
-	response.Output = app.SheetService.Sanitize(output)
+	app.fillResponse(&response, submissionData.Parser, output.Output(), status)
+	response.Hidden = app.runHidden(sub, submissionData, waiter)
+}
+
+// runHidden runs the hidden tests of the sheet on Submit, once the visible
+// ones are done. Returns nil for a Run or a sheet without hidden tests.
+func (app *App) runHidden(
+	sub submission,
+	submissionData services.Correction,
+	waiter container.Waiter,
+) *hiddenResponse {
+	if sub.run || !services.HasTests(submissionData, services.TestsHidden) {
+		return nil
+	}
+	output, status, err := app.ExerciseService.RunTest(
+		services.WithTests(submissionData, services.TestsHidden),
+		sub.filesFor(submissionData),
+		waiter,
+	)
+	if err != nil {
+		var response submitResponse
+		setRunError(&response, err)
+		return &hiddenResponse{StatusCode: response.StatusCode, Tests: []report.TestCase{}, Verdict: response.Verdict}
+	}
+	result, err := report.Parse(submissionData.Parser, app.SheetService.Sanitize(output.Combined()))
+	if err != nil {
+		log.Println(err)
+	}
+	hidden := &hiddenResponse{
+		StatusCode: int(status.StatusCode),
+		Tests:      []report.TestCase{},
+		Verdict:    services.VerdictOf(status, submissionData.Parser != "", result.Tests),
+	}
+	for _, test := range result.Tests {
+		test.Message = ""
+		hidden.Tests = append(hidden.Tests, test)
+	}
+	return hidden
+}
+
+// setRunError tells the learner whether the server is busy, restarting or failed to run the code.
+func setRunError(response *submitResponse, err error) {
+	var busy *container.BusyError
+	if errors.As(err, &busy) {
+		response.Output = busy.Error()
+		response.StatusCode = statusBusy
+		response.Position = busy.Position
+		return
+	}
+	if errors.Is(err, services.ErrShuttingDown) || errors.Is(err, container.ErrImageUnhealthy) {
+		response.Output = err.Error()
+		response.StatusCode = statusBusy
+		return
+	}
+	if errors.Is(err, services.ErrNotEditable) || errors.Is(err, services.ErrNoScratchpad) {
+		response.Output = err.Error()
+		response.StatusCode = http.StatusBadRequest
+		return
+	}
+	log.Println(err)
+	response.Output = "Failed to run the code"
+	response.StatusCode = 520
+	response.Verdict = services.VerdictInternalError
+}
+
+// fillResponse sanitizes the output and parses its tests.
+func (app *App) fillResponse(response *submitResponse, parser string, output container.Output, status container.RunResponse) {
+	sanitized := output.Map(app.SheetService.Sanitize)
+	combined := sanitized.Combined()
+	result, err := report.Parse(parser, combined)
+	if err != nil {
+		log.Println(err)
+	}
+	response.Output = result.Output
+	response.Stdout = sanitized.Stdout
+	response.Stderr = sanitized.Stderr
+	if result.Output == combined {
+		response.Chunks = sanitized.Chunks
+	}
 	response.StatusCode = int(status.StatusCode)
+	response.Truncated = status.OutputTruncated
+	response.Verdict = services.VerdictOf(status, parser != "", result.Tests)
+	if result.Tests != nil {
+		response.Tests = result.Tests
+	}
+}
+
+// submission is the code sent by the learner, either the content of each
+// edited file or a single payload for the submission file of the sheet.
+type submission struct {
+	files   map[string]string
+	payload string
+	// only the visible tests are run, for the Run button
+	run bool
+}
+
+// filesFor returns the content of the submitted files by name.
+func (s submission) filesFor(correction services.Correction) map[string]string {
+	if s.files != nil {
+		return s.files
+	}
+	return map[string]string{correction.SubmissionName: s.payload}
+}
+
+// parseSubmission reads the submitted files and the sheet id from the query or the form.
+// The files are a JSON object of filename to content, "payload" is still
+// accepted for the sheets with a single file. With "tests=visible", the
+// hidden tests are not run.
+func parseSubmission(r *http.Request) (submission, uuid.UUID, error) {
+	var sub submission
+	if err := r.ParseForm(); err != nil {
+		return sub, uuid.UUID{}, errors.New("Unable to parse form")
+	}
+	if files := r.FormValue("files"); files != "" {
+		if err := json.Unmarshal([]byte(files), &sub.files); err != nil || len(sub.files) == 0 {
+			return sub, uuid.UUID{}, errors.New("Invalid files provided")
+		}
+	} else if sub.payload = r.FormValue("payload"); sub.payload == "" {
+		return sub, uuid.UUID{}, errors.New("No payload provided")
+	}
+	sub.run = r.FormValue("tests") == string(services.TestsVisible)
+	sheetId := r.FormValue("sheet")
+	if sheetId == "" {
+		return sub, uuid.UUID{}, errors.New("No sheet id provided")
+	}
+	sheetUUID, err := uuid.Parse(sheetId)
+	if err != nil {
+		return sub, uuid.UUID{}, errors.New("Invalid sheet id")
+	}
+	return sub, sheetUUID, nil
 }
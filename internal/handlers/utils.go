@@ -1,7 +1,72 @@
 package handlers
 
-import "net/http"
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+)
 
 func isFromHtmx(r *http.Request) bool {
 	return r.Header.Get("HX-Request") == "true"
 }
+
+// trustedProxies are the addresses allowed to tell the address of the client
+// with X-Forwarded-For, set in TRUSTED_PROXIES as a comma separated list of
+// addresses or CIDR ranges. None by default, the server being reached directly.
+var trustedProxies = sync.OnceValue(func() []netip.Prefix {
+	var proxies []netip.Prefix
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				log.Printf("Ignoring invalid trusted proxy %q: %v", entry, err)
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		proxies = append(proxies, prefix)
+	}
+	return proxies
+})
+
+// clientID identifies the learner to share the containers fairly. It is the
+// address of the connection, unless it comes from a trusted proxy: then it is
+// the last forwarded address, the one appended by the proxy. The entries before
+// it are sent by the client and can't be trusted.
+func clientID(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isTrustedProxy(addr.Unmap()) {
+		return host
+	}
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		return host
+	}
+	entries := strings.Split(forwarded[len(forwarded)-1], ",")
+	if last := strings.TrimSpace(entries[len(entries)-1]); last != "" {
+		return last
+	}
+	return host
+}
+
+func isTrustedProxy(addr netip.Addr) bool {
+	for _, prefix := range trustedProxies() {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+// Package metrics holds what the Prometheus metrics of the other packages
+// share, and serves them away from the learners.
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DEFAULT_ADDR serves the metrics when METRICS_ADDR is not set, only reachable
+// from the host.
+const DEFAULT_ADDR = "127.0.0.1:9090"
+
+// DefaultBuckets suits durations from a few milliseconds to a minute, in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Addr returns the address serving the metrics, METRICS_ADDR or DEFAULT_ADDR.
+func Addr() string {
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		return addr
+	}
+	return DEFAULT_ADDR
+}
+
+// Handler serves the registered metrics. With a token, the requests must
+// carry it as a bearer token, for an address reachable from outside the host.
+func Handler(token string) http.Handler {
+	handler := promhttp.Handler()
+	if token == "" {
+		return handler
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
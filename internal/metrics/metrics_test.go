@@ -0,0 +1,35 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nexzap/internal/metrics"
+	"testing"
+)
+
+func TestHandler_Token(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		status        int
+	}{
+		{name: "no token", status: http.StatusOK},
+		{name: "valid token", token: "secret", authorization: "Bearer secret", status: http.StatusOK},
+		{name: "missing token", token: "secret", status: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", authorization: "Bearer other", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			metrics.Handler(tt.token).ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
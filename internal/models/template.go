@@ -1,16 +1,20 @@
 package models
 
+import "encoding/json"
+
 type SheetTempl struct {
-	Id                string
-	TutorialId        string
-	Title             string
-	CodeEditor        string
-	SheetContent      string
-	ExerciseContent   string
-	SubmissionContent string
-	NbPage            int
-	MaxPage           int
-	IsLast            bool
+	Id              string
+	TutorialId      string
+	Title           string
+	CodeEditor      string
+	SheetContent    string
+	ExerciseContent string
+	Files           []FileTempl
+	Tests           []FileTempl // visible tests
+	Scratchpad      bool        // code can be run without the tests
+	NbPage          int
+	MaxPage         int
+	IsLast          bool
 }
 
 func NewSheetTempl(
@@ -20,22 +24,51 @@ func NewSheetTempl(
 	codeEditor string,
 	sheetContent string,
 	exerciseContent string,
-	submissionContent string,
+	files []FileTempl,
+	tests []FileTempl,
+	scratchpad bool,
 	nbPage, maxPage int,
 	isLast bool,
 ) SheetTempl {
 	return SheetTempl{
-		Id:                id,
-		TutorialId:        tutorialId,
-		Title:             title,
-		CodeEditor:        codeEditor,
-		SheetContent:      sheetContent,
-		ExerciseContent:   exerciseContent,
-		SubmissionContent: submissionContent,
-		NbPage:            nbPage,
-		MaxPage:           maxPage,
-		IsLast:            isLast,
+		Id:              id,
+		TutorialId:      tutorialId,
+		Title:           title,
+		CodeEditor:      codeEditor,
+		SheetContent:    sheetContent,
+		ExerciseContent: exerciseContent,
+		Files:           files,
+		Tests:           tests,
+		Scratchpad:      scratchpad,
+		NbPage:          nbPage,
+		MaxPage:         maxPage,
+		IsLast:          isLast,
+	}
+}
+
+// FilesJSON returns the files for the editor script.
+func (s SheetTempl) FilesJSON() string {
+	files, err := json.Marshal(s.Files)
+	if err != nil || s.Files == nil {
+		return "[]"
+	}
+	return string(files)
+}
+
+// FileTempl is a file of the sheet, like the ones the learner edits or the visible tests.
+type FileTempl struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+func NewFilesTempl(names, contents []string) []FileTempl {
+	files := make([]FileTempl, 0, len(names))
+	for i, name := range names {
+		if i < len(contents) {
+			files = append(files, FileTempl{Name: name, Content: contents[i]})
+		}
 	}
+	return files
 }
 
 type ListTutorialTempl struct {
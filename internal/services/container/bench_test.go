@@ -0,0 +1,58 @@
+package container_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"nexzap/internal/services/container"
+)
+
+// BenchmarkRunModes compares a run in a started container with one executed
+// in a running container, from the wait for a container to its release. It
+// needs a Docker daemon and an image having sh, NEXZAP_BENCH_IMAGE or alpine:
+//
+//	go test ./internal/services/container -run '^$' -bench RunModes
+func BenchmarkRunModes(b *testing.B) {
+	ctx := context.Background()
+	rt, err := container.NewDockerRuntimeFromEnv()
+	if err != nil {
+		b.Skipf("no Docker client: %v", err)
+	}
+	image := os.Getenv("NEXZAP_BENCH_IMAGE")
+	if image == "" {
+		image = "alpine"
+	}
+	if _, err := rt.ImageID(ctx, image); err != nil {
+		b.Skipf("image %s not available: %v", image, err)
+	}
+	files := []container.File{{Name: "main.sh", Content: "echo ok"}}
+
+	for _, mode := range []container.RunMode{container.ModeStart, container.ModeExec} {
+		b.Run(string(mode), func(b *testing.B) {
+			pool := container.NewPool()
+			defer pool.CleanAll(ctx, rt)
+			tutorial := container.Tutorial{Image: image, Command: []string{"sh", "main.sh"}, Mode: mode}
+			imagePool := pool.GetImagePool(ctx, rt, tutorial)
+
+			b.ResetTimer()
+			for range b.N {
+				ctn, err := imagePool.GetContainer(ctx, rt, container.Waiter{})
+				if err != nil {
+					b.Fatalf("GetContainer failed: %v", err)
+				}
+				var status container.RunResponse
+				if mode == container.ModeExec {
+					status, err = container.StreamExec(ctx, rt, ctn, tutorial, files, io.Discard, io.Discard)
+				} else {
+					status, err = container.Stream(ctx, rt, ctn, files, container.DEFAULT_OUTPUT_LIMIT, io.Discard, io.Discard)
+				}
+				if err != nil || status.StatusCode != 0 {
+					b.Fatalf("run failed with code %d: %v", status.StatusCode, err)
+				}
+				imagePool.FreeContainer(ctx, rt, ctn)
+			}
+		})
+	}
+}
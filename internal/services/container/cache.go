@@ -0,0 +1,61 @@
+package container
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CACHE_DIR holds the copies of the caches during a run
+const CACHE_DIR = "/tmp/cache"
+
+var (
+	cachePathReg = regexp.MustCompile(`^/[A-Za-z0-9._/-]+$`)
+	cacheEnvReg  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Cache is a build cache baked in the image, like a cargo target directory
+// or a GOCACHE, so the submissions only compile their own code. The image
+// keeps it read-only and each run works on its own copy, a submission can't
+// tamper with the builds of the next ones.
+type Cache struct {
+	// Path of the cache in the image
+	Path string
+	// Env is the variable pointing the toolchain to the copy, e.g. GOCACHE
+	Env string
+}
+
+// Validate checks a cache set by a tutorial. Both values end up in a shell script.
+func (c Cache) Validate() error {
+	if !cachePathReg.MatchString(c.Path) {
+		return fmt.Errorf("cache path %q must be absolute, without spaces nor quotes", c.Path)
+	}
+	if !cacheEnvReg.MatchString(c.Env) {
+		return fmt.Errorf("cache env %q is not a variable name", c.Env)
+	}
+	return nil
+}
+
+func (c Cache) String() string {
+	return c.Env + "=" + c.Path
+}
+
+// copyCaches returns the shell commands copying the caches under base and
+// exporting their variables. The copies keep the modification times, cargo
+// relies on them. A cache missing from the image only slows the builds down,
+// but a partial copy, like when /tmp is full, fails the run: the builds would
+// go wrong without a word.
+func copyCaches(base string, caches []Cache) string {
+	var b strings.Builder
+	for i, cache := range caches {
+		dir := fmt.Sprintf("%s/%d", base, i)
+		fmt.Fprintf(&b, "if [ -d %s ]; then mkdir -p %s && cp -Rp %s/. %s/ || { echo %q >&2; exit 1; }; fi; export %s=%s; ",
+			cache.Path, dir, cache.Path, dir, cacheCopyFailed(cache), cache.Env, dir)
+	}
+	return b.String()
+}
+
+// cacheCopyFailed is the message of a run whose cache could not be copied.
+func cacheCopyFailed(cache Cache) string {
+	return fmt.Sprintf("nexzap: failed to copy the build cache %s to /tmp, raise workspace_size in the [sandbox] of the tutorial", cache.Path)
+}
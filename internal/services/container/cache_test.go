@@ -0,0 +1,31 @@
+package container_test
+
+import (
+	"testing"
+
+	"nexzap/internal/services/container"
+)
+
+func TestCache_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cache container.Cache
+		valid bool
+	}{
+		{"go", container.Cache{Path: "/cache/go", Env: "GOCACHE"}, true},
+		{"cargo", container.Cache{Path: "/cache/target", Env: "CARGO_TARGET_DIR"}, true},
+		{"relative path", container.Cache{Path: "cache/go", Env: "GOCACHE"}, false},
+		// both end up in the script of the container
+		{"quoted path", container.Cache{Path: "/cache/'go", Env: "GOCACHE"}, false},
+		{"command in env", container.Cache{Path: "/cache/go", Env: "GOCACHE=x; rm"}, false},
+		{"empty env", container.Cache{Path: "/cache/go"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cache.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("expected valid %v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
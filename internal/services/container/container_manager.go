@@ -4,141 +4,148 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
-	"fmt"
 	"io"
 	"log"
+	"os"
 	"time"
 
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
-type RunResponse = container.WaitResponse
+// STATUS_KILLED is the status code of a command killed by SIGKILL
+const STATUS_KILLED = 128 + 9
+
+// RunResponse is the outcome of a run.
+type RunResponse struct {
+	StatusCode int64
+	// OutputTruncated tells the output went over the limit, the container
+	// was then killed
+	OutputTruncated bool
+	// OOMKilled tells the command was killed for going over the memory limit
+	OOMKilled bool
+	// TimedOut tells the run was stopped by the time limit. It is set by the
+	// caller owning the deadline.
+	TimedOut bool
+}
 
-// Run executes a container with the provided files and returns the output.
+// Run executes a container with the provided files and returns its output,
+// stdout and stderr combined truncated to outputLimit bytes. On error, the
+// output is the part read before it.
 func Run(
 	ctx context.Context,
-	cli *client.Client,
+	rt Runtime,
 	ctn string,
 	files []File,
-) (string, RunResponse, error) {
+	outputLimit int64,
+) (Output, RunResponse, error) {
+	var recorder OutputRecorder
+	status, err := Stream(ctx, rt, ctn, files, outputLimit, recorder.Writer(STDOUT), recorder.Writer(STDERR))
+	return recorder.Output(), status, err
+}
+
+// Stream executes a container with the provided files and writes its stdout
+// and stderr as they are produced, up to outputLimit bytes. Going over the
+// limit kills the container, so an endless print does not run until the timeout.
+// It returns once the container has stopped.
+func Stream(
+	ctx context.Context,
+	rt Runtime,
+	ctn string,
+	files []File,
+	outputLimit int64,
+	stdout, stderr io.Writer,
+) (RunResponse, error) {
+	// The container is killed and removed even once the run timed out
+	cleanupCtx := context.WithoutCancel(ctx)
 	var err error
 	defer func() {
 		if err != nil {
-			StopAndRemove(ctx, cli, ctn)
+			removeContainer(cleanupCtx, rt, ctn)
 		}
 	}()
-	archive, err := createTarArchive(files)
-	if err != nil {
-		return "", RunResponse{}, err
-	}
 
-	err = cli.CopyToContainer(ctx, ctn, "/", archive, container.CopyToContainerOptions{})
+	err = rt.CopyFiles(ctx, ctn, files)
 	if err != nil {
-		return "", RunResponse{}, err
+		return RunResponse{}, err
 	}
 
 	startTime := time.Now()
-	if err := cli.ContainerStart(ctx, ctn, container.StartOptions{}); err != nil {
-		return "", RunResponse{}, err
-	}
-
-	statusCh, errCh := cli.ContainerWait(ctx, ctn, container.WaitConditionNotRunning)
-	var status container.WaitResponse
-	select {
-	case err := <-errCh:
-		if err != nil {
-			return "", RunResponse{}, err
-		}
-	case status = <-statusCh:
+	if err = rt.Start(ctx, ctn); err != nil {
+		return RunResponse{}, err
 	}
 
-	logs, err := cli.ContainerLogs(ctx, ctn, container.LogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-		Since:      startTime.Format(time.RFC3339),
-	})
+	logs, err := rt.Logs(ctx, ctn, LogsOptions{Since: startTime, Follow: true})
 	if err != nil {
-		return "", RunResponse{}, err
+		return RunResponse{}, err
 	}
 	defer logs.Close()
 
-	var logBytes bytes.Buffer
-	_, err = io.Copy(&logBytes, logs)
+	// The logs are multiplexed, each frame has a header telling its stream.
+	// The stream ends when the container stops.
+	limit := &outputCap{remaining: outputLimit, onExceed: func() {
+		if err := rt.Kill(cleanupCtx, ctn); err != nil {
+			log.Println(err)
+		}
+	}}
+	_, err = stdcopy.StdCopy(limit.writer(stdout), limit.writer(stderr), logs)
 	if err != nil {
-		return "", RunResponse{}, err
+		return RunResponse{}, err
 	}
 
-	return logBytes.String(), status, nil
-}
-
-// createContainer Creates a new container. If the WarmupDir is not empty, runs a warmup phase.
-func createContainer(
-	ctx context.Context,
-	cli *client.Client,
-	lang Tutorial,
-) (container.CreateResponse, error) {
-	var emptyResp container.CreateResponse
-
-	resp, err := cli.ContainerCreate(ctx, &container.Config{
-		Image:      lang.Image,
-		Cmd:        lang.Command,
-		WorkingDir: "/workspace",
-		Tty:        false,
-	}, &container.HostConfig{
-		CapDrop: []string{"ALL"},
-		CapAdd:  []string{},
-		// Prevent mounting the Docker socket or other sensitive paths
-		Binds: nil,
-		Resources: container.Resources{
-			Memory:    512 * 1024 * 1024,
-			CPUQuota:  100000,
-			CPUPeriod: 100000,
-		},
-		// Isolate from host network for additional security
-		NetworkMode: "none",
-	}, nil, nil, "")
+	status, err := rt.Wait(ctx, ctn)
 	if err != nil {
-		fmt.Println(err)
-		StopAndRemove(ctx, cli, resp.ID) // in case the error still creates the container
-		return emptyResp, err
+		return RunResponse{}, err
 	}
-
-	return resp, nil
+	status.OutputTruncated = limit.exceeded
+	return status, nil
 }
 
-// StopAndRemove stops and removes a container.
-func StopAndRemove(ctx context.Context, cli *client.Client, id string) {
-	timeout := 10
-	if err := cli.ContainerStop(ctx, id, container.StopOptions{Timeout: &timeout}); err != nil {
-		if !client.IsErrNotFound(err) {
-			log.Println(err)
-		}
+// createContainer creates a labelled container, tracks it as owned and counts it.
+// The containers of ModeExec are started right away.
+func createContainer(ctx context.Context, rt Runtime, lang Tutorial, kind PoolKind) (string, error) {
+	id, err := rt.Create(ctx, lang, Labels(lang.Image, kind))
+	if err != nil {
+		return id, err
 	}
-
-	if err := cli.ContainerRemove(ctx, id, container.RemoveOptions{}); err != nil {
-		if !client.IsErrNotFound(err) {
-			log.Println(err)
+	track(id)
+	containersCreated.WithLabelValues(lang.Image).Inc()
+	if lang.Mode == ModeExec {
+		if err := rt.Start(ctx, id); err != nil {
+			removeContainer(ctx, rt, id)
+			return "", err
 		}
 	}
+	return id, nil
+}
+
+// removeContainer removes a container, stops tracking it and counts it.
+func removeContainer(ctx context.Context, rt Runtime, id string) {
+	rt.Remove(ctx, id)
+	untrack(id)
+	containersRemoved.Inc()
 }
 
 type File struct {
 	Name    string
 	Content string
+	Mode    os.FileMode // 0644 when zero
 }
 
-// createTarArchive creates a tar archive from the provided files.
-func createTarArchive(files []File) (*bytes.Buffer, error) {
+// createTarArchive creates a tar archive from the provided files, their names prefixed.
+func createTarArchive(files []File, prefix string) (*bytes.Buffer, error) {
 	var buf bytes.Buffer
 	tw := tar.NewWriter(&buf)
 	defer tw.Close()
 
 	for _, file := range files {
+		mode := file.Mode.Perm()
+		if mode == 0 {
+			mode = 0644
+		}
 		header := &tar.Header{
-			Name:    "workspace/" + file.Name,
+			Name:    prefix + file.Name,
 			Size:    int64(len(file.Content)),
-			Mode:    0644,
+			Mode:    int64(mode),
 			ModTime: time.Now(),
 		}
 
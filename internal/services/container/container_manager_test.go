@@ -0,0 +1,180 @@
+package container_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"nexzap/internal/services/container"
+)
+
+func echoHandler(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+	if strings.Contains(files["main.go"], "fail") {
+		return container.FakeResult{Stdout: "FAIL", StatusCode: 1}
+	}
+	return container.FakeResult{Stdout: "ok " + strings.Join(tutorial.Command, " ")}
+}
+
+func TestRun_FakeRuntime(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	tutorial := container.Tutorial{Image: "gotest", Command: []string{"go", "test"}}
+
+	ctn, err := rt.Create(ctx, tutorial, nil)
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+
+	tests := []struct {
+		content string
+		code    int64
+		output  string
+	}{
+		{"package main", 0, "ok go test"},
+		{"package main // fail", 1, "FAIL"},
+	}
+	for _, tt := range tests {
+		output, status, err := container.Run(ctx, rt, ctn, []container.File{{Name: "main.go", Content: tt.content}}, container.DEFAULT_OUTPUT_LIMIT)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if status.StatusCode != tt.code {
+			t.Errorf("expected code %d, got %d", tt.code, status.StatusCode)
+		}
+		if !strings.Contains(output.Stdout, tt.output) {
+			t.Errorf("expected output to contain %q, got %q", tt.output, output)
+		}
+	}
+}
+
+func TestRun_RemovesContainerOnError(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+
+	_, _, err := container.Run(ctx, rt, "missing", nil, container.DEFAULT_OUTPUT_LIMIT)
+	if err == nil {
+		t.Fatal("expected an error for an unknown container")
+	}
+	if len(rt.Containers()) != 0 {
+		t.Errorf("expected no container left, got %v", rt.Containers())
+	}
+}
+
+func TestStream_FakeRuntime(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		return container.FakeResult{Stdout: "compiled", Stderr: "warning", StatusCode: 101}
+	})
+	ctn, err := rt.Create(ctx, container.Tutorial{Image: "rusttest"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+
+	var stdout, stderr strings.Builder
+	status, err := container.Stream(ctx, rt, ctn, nil, container.DEFAULT_OUTPUT_LIMIT, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if status.StatusCode != 101 {
+		t.Errorf("expected code 101, got %d", status.StatusCode)
+	}
+	if stdout.String() != "compiled" || stderr.String() != "warning" {
+		t.Errorf("unexpected streams: stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestStream_TimeoutRemovesContainer(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		<-release
+		return container.FakeResult{}
+	})
+	ctn, err := rt.Create(context.Background(), container.Tutorial{Image: "gotest"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+
+	// The fake ignores a Remove with a done context, like Docker
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var stdout, stderr strings.Builder
+	if _, err := container.Stream(ctx, rt, ctn, nil, container.DEFAULT_OUTPUT_LIMIT, &stdout, &stderr); err == nil {
+		t.Fatal("expected the run to time out")
+	}
+	if len(rt.Containers()) != 0 {
+		t.Errorf("expected the timed out container removed, got %v", rt.Containers())
+	}
+}
+
+func TestStream_OutputLimit(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		return container.FakeResult{Stdout: "0123456789", Stderr: "abcdef"}
+	})
+	ctn, err := rt.Create(ctx, container.Tutorial{Image: "gotest"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+
+	var stdout, stderr strings.Builder
+	status, err := container.Stream(ctx, rt, ctn, nil, 12, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if stdout.String() != "0123456789" || stderr.String() != "ab" {
+		t.Errorf("expected 12 bytes in total, got stdout %q and stderr %q", stdout.String(), stderr.String())
+	}
+	if !status.OutputTruncated || rt.Kills != 1 {
+		t.Errorf("expected the run to be truncated and killed, got %+v and %d kills", status, rt.Kills)
+	}
+
+	// Output fitting exactly is not truncated
+	stdout.Reset()
+	stderr.Reset()
+	status, err = container.Stream(ctx, rt, ctn, nil, 16, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if status.OutputTruncated || rt.Kills != 1 {
+		t.Errorf("expected the run not to be truncated, got %+v and %d kills", status, rt.Kills)
+	}
+}
+
+func TestRun_SeparatesStreams(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		return container.FakeResult{Stdout: "compiling", Stderr: "error: expected ;", StatusCode: 1}
+	})
+	ctn, err := rt.Create(ctx, container.Tutorial{Image: "gotest"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+
+	output, _, err := container.Run(ctx, rt, ctn, nil, container.DEFAULT_OUTPUT_LIMIT)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	expected := container.Output{
+		Stdout: "compiling",
+		Stderr: "error: expected ;",
+		Chunks: []container.Chunk{
+			{Stream: container.STDOUT, Text: "compiling"},
+			{Stream: container.STDERR, Text: "error: expected ;"},
+		},
+	}
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("expected %+v, got %+v", expected, output)
+	}
+
+	// The limit applies to both streams together
+	output, _, err = container.Run(ctx, rt, ctn, nil, 12)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if output.Combined() != "compilingerr" {
+		t.Errorf("expected the output to be truncated, got %q", output.Combined())
+	}
+}
@@ -0,0 +1,530 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// EXEC_POLL_INTERVAL is the time between two checks of an execution, the API
+// has no way to wait for one.
+const EXEC_POLL_INTERVAL = 20 * time.Millisecond
+
+// HEARTBEAT_VOLUME prefixes the volumes holding the heartbeats in their labels.
+// The volumes are empty, they are only the cheapest labelled object.
+const HEARTBEAT_VOLUME = "nexzap-heartbeat-"
+
+// OOM_EVENTS_COMMAND prints the memory events of the cgroup of a container,
+// counting its processes killed for the memory, for cgroup v2 or v1.
+var OOM_EVENTS_COMMAND = []string{"sh", "-c", "cat /sys/fs/cgroup/memory.events /sys/fs/cgroup/memory/memory.oom_control 2>/dev/null; true"}
+
+// DockerRuntime runs the containers through the Docker API.
+type DockerRuntime struct {
+	cli *client.Client
+	mu  sync.Mutex
+	// OOM kills counted in the cgroup of each container after its last
+	// execution, and before each execution running, see Exec
+	oomKills     map[string]int
+	execOOMKills map[string]execBaseline
+}
+
+// execBaseline is the count of OOM kills of a container before an execution.
+type execBaseline struct {
+	container string
+	kills     int
+}
+
+// NewDockerRuntime creates a runtime from an existing Docker client.
+func NewDockerRuntime(cli *client.Client) *DockerRuntime {
+	return &DockerRuntime{cli: cli, oomKills: make(map[string]int), execOOMKills: make(map[string]execBaseline)}
+}
+
+// NewDockerRuntimeForNode creates a runtime with a client connected to a worker node.
+func NewDockerRuntimeForNode(node NodeConfig) (*DockerRuntime, error) {
+	opts := []client.Opt{client.WithHost(node.Host), client.WithAPIVersionNegotiation()}
+	if node.TLS != "" {
+		opts = append(opts, client.WithTLSClientConfig(
+			filepath.Join(node.TLS, "ca.pem"),
+			filepath.Join(node.TLS, "cert.pem"),
+			filepath.Join(node.TLS, "key.pem"),
+		))
+	}
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewDockerRuntime(cli), nil
+}
+
+// NewDockerRuntimeFromEnv creates a runtime with a client configured from the environment.
+func NewDockerRuntimeFromEnv() (*DockerRuntime, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	return NewDockerRuntime(cli), nil
+}
+
+// Create creates a new container with networking disabled and all capabilities dropped.
+// The command runs as the user of the sandbox, on a read-only root filesystem
+// unless the tutorial needs it writable. With ModeExec, the container idles
+// and the runs are executed in it.
+func (d *DockerRuntime) Create(ctx context.Context, lang Tutorial, labels map[string]string) (string, error) {
+	limits := lang.Limits.WithDefaults()
+	sandbox := lang.Sandbox.WithDefaults()
+	securityOpt := []string{"no-new-privileges"}
+	if sandbox.Seccomp != "" {
+		securityOpt = append(securityOpt, "seccomp="+sandbox.Seccomp)
+	}
+	cmd := sandbox.command(lang.Command, lang.Caches)
+	if lang.Mode == ModeExec {
+		cmd = IDLE_COMMAND
+	}
+	resp, err := d.cli.ContainerCreate(ctx, &container.Config{
+		Image:      lang.ref(),
+		Cmd:        cmd,
+		WorkingDir: WORKSPACE,
+		User:       sandbox.User,
+		// The home of the image may not be writable, toolchains put their caches in it
+		Env:     []string{"HOME=/tmp"},
+		Volumes: map[string]struct{}{INBOX: {}},
+		Tty:     false,
+		Labels:  labels,
+	}, &container.HostConfig{
+		CapDrop:     []string{"ALL"},
+		CapAdd:      []string{},
+		SecurityOpt: securityOpt,
+		// Prevent mounting the Docker socket or other sensitive paths
+		Binds:          nil,
+		ReadonlyRootfs: !sandbox.Writable,
+		Tmpfs: map[string]string{
+			WORKSPACE: sandbox.tmpfs(),
+			"/tmp":    sandbox.tmpfs(),
+		},
+		Resources: container.Resources{
+			Memory:    limits.Memory,
+			CPUQuota:  int64(limits.CPUs * 100000),
+			CPUPeriod: 100000,
+			PidsLimit: &limits.PidsLimit,
+			Ulimits: []*container.Ulimit{
+				{Name: "nofile", Soft: sandbox.NoFile, Hard: sandbox.NoFile},
+				{Name: "nproc", Soft: sandbox.NProc, Hard: sandbox.NProc},
+			},
+		},
+		// Isolate from host network for additional security
+		NetworkMode: "none",
+		// Bound the logs kept on the disk of the host, the output read is
+		// capped anyway. Room is left for the encoding of json-file.
+		LogConfig: container.LogConfig{
+			Type: "json-file",
+			Config: map[string]string{
+				"max-size": strconv.FormatInt(LOG_SIZE_FACTOR*limits.OutputLimit, 10),
+				"max-file": "1",
+			},
+		},
+	}, nil, nil, "")
+	if err != nil {
+		log.Println(err)
+		d.Remove(ctx, resp.ID) // in case the error still creates the container
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// CopyFiles copies the files as a tar archive in the inbox of the container,
+// a volume so it works on a read-only root filesystem.
+func (d *DockerRuntime) CopyFiles(ctx context.Context, id string, files []File) error {
+	archive, err := createTarArchive(files, "")
+	if err != nil {
+		return err
+	}
+	return d.cli.CopyToContainer(ctx, id, INBOX, archive, container.CopyToContainerOptions{})
+}
+
+// Start starts the container.
+func (d *DockerRuntime) Start(ctx context.Context, id string) error {
+	return d.cli.ContainerStart(ctx, id, container.StartOptions{})
+}
+
+// Wait waits for the container to stop.
+func (d *DockerRuntime) Wait(ctx context.Context, id string) (RunResponse, error) {
+	statusCh, errCh := d.cli.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return RunResponse{}, err
+	case status := <-statusCh:
+		if status.Error != nil {
+			return RunResponse{}, errors.New(status.Error.Message)
+		}
+		// The exit code of an OOM kill is the same as any SIGKILL
+		inspect, err := d.cli.ContainerInspect(ctx, id)
+		if err != nil {
+			return RunResponse{}, err
+		}
+		return RunResponse{
+			StatusCode: status.StatusCode,
+			OOMKilled:  inspect.State != nil && inspect.State.OOMKilled,
+		}, nil
+	}
+}
+
+// Kill sends SIGKILL to the command of the container.
+func (d *DockerRuntime) Kill(ctx context.Context, id string) error {
+	err := d.cli.ContainerKill(ctx, id, "KILL")
+	// the container stopped in the meantime
+	if errdefs.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// Logs returns the multiplexed stdout and stderr of the container.
+func (d *DockerRuntime) Logs(ctx context.Context, id string, opts LogsOptions) (io.ReadCloser, error) {
+	return d.cli.ContainerLogs(ctx, id, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Since:      opts.Since.Format(time.RFC3339),
+		Follow:     opts.Follow,
+	})
+}
+
+// Remove stops and removes a container.
+func (d *DockerRuntime) Remove(ctx context.Context, id string) {
+	timeout := 10
+	if err := d.cli.ContainerStop(ctx, id, container.StopOptions{Timeout: &timeout}); err != nil {
+		if !client.IsErrNotFound(err) {
+			log.Println(err)
+		}
+	}
+
+	// along with its inbox
+	if err := d.cli.ContainerRemove(ctx, id, container.RemoveOptions{RemoveVolumes: true}); err != nil {
+		if !client.IsErrNotFound(err) {
+			log.Println(err)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.oomKills, id)
+	// of the executions never waited for, like the timed out ones
+	for execID, baseline := range d.execOOMKills {
+		if baseline.container == id {
+			delete(d.execOOMKills, execID)
+		}
+	}
+}
+
+// Inspect returns the status of the container, ErrNotFound once removed.
+func (d *DockerRuntime) Inspect(ctx context.Context, id string) (ContainerState, error) {
+	inspect, err := d.cli.ContainerInspect(ctx, id)
+	if errdefs.IsNotFound(err) {
+		return ContainerState{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return ContainerState{}, err
+	}
+	if inspect.State == nil {
+		return ContainerState{}, nil
+	}
+	return ContainerState{Status: inspect.State.Status}, nil
+}
+
+// List returns the containers having all the labels.
+func (d *DockerRuntime) List(ctx context.Context, labels map[string]string) ([]ContainerInfo, error) {
+	args := filters.NewArgs()
+	for key, value := range labels {
+		args.Add("label", key+"="+value)
+	}
+	ctns, err := d.cli.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]ContainerInfo, 0, len(ctns))
+	for _, ctn := range ctns {
+		infos = append(infos, ContainerInfo{ID: ctn.ID, Labels: ctn.Labels})
+	}
+	return infos, nil
+}
+
+// Heartbeat creates a new heartbeat volume, then removes the older ones of the
+// instance, so the instance always has one.
+func (d *DockerRuntime) Heartbeat(ctx context.Context, instance string, at time.Time) error {
+	_, err := d.cli.VolumeCreate(ctx, volume.CreateOptions{
+		Name: fmt.Sprintf("%s%s-%d", HEARTBEAT_VOLUME, instance, at.UnixNano()),
+		Labels: map[string]string{
+			LABEL_APP:       App,
+			LABEL_INSTANCE:  instance,
+			LABEL_HEARTBEAT: at.UTC().Format(time.RFC3339Nano),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return d.removeHeartbeats(ctx, instance, at)
+}
+
+func (d *DockerRuntime) Instances(ctx context.Context) (map[string]time.Time, error) {
+	volumes, err := d.heartbeats(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	instances := map[string]time.Time{}
+	for _, v := range volumes {
+		at, err := time.Parse(time.RFC3339Nano, v.Labels[LABEL_HEARTBEAT])
+		if err != nil {
+			continue
+		}
+		instance := v.Labels[LABEL_INSTANCE]
+		if at.After(instances[instance]) {
+			instances[instance] = at
+		}
+	}
+	return instances, nil
+}
+
+func (d *DockerRuntime) ForgetInstance(ctx context.Context, instance string) error {
+	return d.removeHeartbeats(ctx, instance, time.Time{})
+}
+
+// removeHeartbeats removes the heartbeat volumes of the instance older than
+// before, or all of them when before is zero.
+func (d *DockerRuntime) removeHeartbeats(ctx context.Context, instance string, before time.Time) error {
+	volumes, err := d.heartbeats(ctx, instance)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, v := range volumes {
+		at, err := time.Parse(time.RFC3339Nano, v.Labels[LABEL_HEARTBEAT])
+		if before.IsZero() || err != nil || at.Before(before) {
+			if err := d.cli.VolumeRemove(ctx, v.Name, true); err != nil && !errdefs.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// heartbeats returns the heartbeat volumes of the instance, or of every
+// instance when empty.
+func (d *DockerRuntime) heartbeats(ctx context.Context, instance string) ([]*volume.Volume, error) {
+	args := filters.NewArgs(filters.Arg("label", LABEL_APP+"="+App), filters.Arg("label", LABEL_HEARTBEAT))
+	if instance != "" {
+		args.Add("label", LABEL_INSTANCE+"="+instance)
+	}
+	resp, err := d.cli.VolumeList(ctx, volume.ListOptions{Filters: args})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Volumes, nil
+}
+
+// Exec executes the command of a run as the user of the sandbox, once its files
+// are moved from the inbox to its directory of the workspace. Docker only
+// reports the OOM kills of the main process of a container, so the ones of
+// the cgroup are counted before the execution and compared by ExecWait.
+func (d *DockerRuntime) Exec(ctx context.Context, id string, opts ExecOptions) (string, io.ReadCloser, error) {
+	d.mu.Lock()
+	kills, counted := d.oomKills[id]
+	d.mu.Unlock()
+	if !counted {
+		var err error
+		if kills, err = d.countOOMKills(ctx, id); err != nil {
+			log.Printf("Failed to count the OOM kills of %s: %v", id, err)
+			kills = -1
+		}
+	}
+
+	resp, err := d.cli.ContainerExecCreate(ctx, id, container.ExecOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		WorkingDir:   WORKSPACE,
+		Cmd:          opts.command(),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	attach, err := d.cli.ContainerExecAttach(ctx, resp.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return "", nil, err
+	}
+	// The hijacked connection outlives the context otherwise
+	stop := context.AfterFunc(ctx, attach.Close)
+	if kills >= 0 {
+		d.mu.Lock()
+		d.execOOMKills[resp.ID] = execBaseline{container: id, kills: kills}
+		d.mu.Unlock()
+	}
+	return resp.ID, &hijackedReader{attach: attach, stop: stop}, nil
+}
+
+// ExecWait polls the execution until it is over. An execution started by Exec
+// is OOMKilled when the cgroup of the container counted a kill meanwhile.
+func (d *DockerRuntime) ExecWait(ctx context.Context, execID string) (RunResponse, error) {
+	d.mu.Lock()
+	before, counted := d.execOOMKills[execID]
+	delete(d.execOOMKills, execID)
+	d.mu.Unlock()
+	for {
+		inspect, err := d.cli.ContainerExecInspect(ctx, execID)
+		if err != nil {
+			return RunResponse{}, err
+		}
+		if !inspect.Running {
+			status := RunResponse{StatusCode: int64(inspect.ExitCode)}
+			if counted {
+				after, err := d.countOOMKills(ctx, inspect.ContainerID)
+				if err != nil {
+					log.Printf("Failed to count the OOM kills of %s: %v", inspect.ContainerID, err)
+					return status, nil
+				}
+				d.mu.Lock()
+				d.oomKills[inspect.ContainerID] = after
+				d.mu.Unlock()
+				status.OOMKilled = after > before.kills
+			}
+			return status, nil
+		}
+		select {
+		case <-time.After(EXEC_POLL_INTERVAL):
+		case <-ctx.Done():
+			return RunResponse{}, ctx.Err()
+		}
+	}
+}
+
+// CleanRun kills the processes left by the run, which all belong to the user
+// of the sandbox, then empties the workspace and /tmp, the HOME of the
+// commands, so nothing written by the run is seen by the next one. The inbox is
+// written by the daemon, only root can remove the files of the run.
+func (d *DockerRuntime) CleanRun(ctx context.Context, id string, dir string) error {
+	if err := d.execDetached(ctx, id, "", []string{"sh", "-c", "kill -9 -1 2>/dev/null; true"}); err != nil {
+		return err
+	}
+	script := fmt.Sprintf("rm -rf %s %s %s/%s", dirContent(WORKSPACE), dirContent("/tmp"), INBOX, dir)
+	return d.execDetached(ctx, id, "0:0", []string{"sh", "-c", script})
+}
+
+// dirContent returns the shell patterns matching everything in a directory,
+// hidden files included.
+func dirContent(dir string) string {
+	return fmt.Sprintf("%s/* %s/.[!.]* %s/..?*", dir, dir, dir)
+}
+
+// countOOMKills returns the number of processes of the container killed so far
+// for going over its memory limit.
+func (d *DockerRuntime) countOOMKills(ctx context.Context, id string) (int, error) {
+	resp, err := d.cli.ContainerExecCreate(ctx, id, container.ExecOptions{AttachStdout: true, Cmd: OOM_EVENTS_COMMAND})
+	if err != nil {
+		return 0, err
+	}
+	attach, err := d.cli.ContainerExecAttach(ctx, resp.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer attach.Close()
+	var events strings.Builder
+	if _, err := stdcopy.StdCopy(&events, io.Discard, attach.Reader); err != nil {
+		return 0, err
+	}
+	return parseOOMKills(events.String())
+}
+
+// parseOOMKills reads the oom_kill counter of memory.events or memory.oom_control.
+func parseOOMKills(events string) (int, error) {
+	for _, line := range strings.Split(events, "\n") {
+		if value, found := strings.CutPrefix(line, "oom_kill "); found {
+			return strconv.Atoi(strings.TrimSpace(value))
+		}
+	}
+	return 0, errors.New("no oom_kill counter in the memory events of the cgroup")
+}
+
+// execDetached executes a command without its output and waits for it to
+// succeed. An empty user is the one of the container.
+func (d *DockerRuntime) execDetached(ctx context.Context, id string, user string, cmd []string) error {
+	resp, err := d.cli.ContainerExecCreate(ctx, id, container.ExecOptions{User: user, Cmd: cmd})
+	if err != nil {
+		return err
+	}
+	if err := d.cli.ContainerExecStart(ctx, resp.ID, container.ExecStartOptions{Detach: true}); err != nil {
+		return err
+	}
+	status, err := d.ExecWait(ctx, resp.ID)
+	if err != nil {
+		return err
+	}
+	if status.StatusCode != 0 {
+		return fmt.Errorf("%q exited with code %d", strings.Join(cmd, " "), status.StatusCode)
+	}
+	return nil
+}
+
+// hijackedReader reads the output of an execution and closes its connection.
+type hijackedReader struct {
+	attach types.HijackedResponse
+	stop   func() bool
+}
+
+func (h *hijackedReader) Read(p []byte) (int, error) {
+	return h.attach.Reader.Read(p)
+}
+
+func (h *hijackedReader) Close() error {
+	h.stop()
+	h.attach.Close()
+	return nil
+}
+
+// BuildImage builds an image from the build context and tags it.
+func (d *DockerRuntime) BuildImage(ctx context.Context, files []File, tags []string) error {
+	archive, err := createTarArchive(files, "")
+	if err != nil {
+		return err
+	}
+	resp, err := d.cli.ImageBuild(ctx, archive, types.ImageBuildOptions{
+		Tags:        tags,
+		Remove:      true,
+		ForceRemove: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return readBuildOutput(resp.Body)
+}
+
+// TagImage adds a reference to an image.
+func (d *DockerRuntime) TagImage(ctx context.Context, source, target string) error {
+	return d.cli.ImageTag(ctx, source, target)
+}
+
+// Ping checks the daemon answers.
+func (d *DockerRuntime) Ping(ctx context.Context) error {
+	_, err := d.cli.Ping(ctx)
+	return err
+}
+
+// ImageID returns the id of an image.
+func (d *DockerRuntime) ImageID(ctx context.Context, ref string) (string, error) {
+	inspect, err := d.cli.ImageInspect(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	return inspect.ID, nil
+}
@@ -0,0 +1,122 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/google/uuid"
+)
+
+// RunMode tells how the runs use the containers of a pool.
+type RunMode string
+
+const (
+	// ModeStart starts a stopped container for each run, its command being
+	// the one of the tutorial.
+	ModeStart RunMode = "start"
+	// ModeExec keeps the containers running and executes each run in a
+	// directory of its own, saving the start of a container. A container is
+	// only replaced when a run kills it.
+	ModeExec RunMode = "exec"
+)
+
+// ParseRunMode parses a mode name, an empty name being ModeStart.
+func ParseRunMode(name string) (RunMode, error) {
+	switch RunMode(name) {
+	case "", ModeStart:
+		return ModeStart, nil
+	case ModeExec:
+		return ModeExec, nil
+	default:
+		return "", fmt.Errorf("unknown run mode %q", name)
+	}
+}
+
+// IDLE_COMMAND keeps the containers of ModeExec running between runs
+var IDLE_COMMAND = []string{"tail", "-f", "/dev/null"}
+
+// ExecOptions describes a command executed in a running container.
+type ExecOptions struct {
+	Cmd []string
+	// Dir is the directory of the run under the inbox and the workspace. The
+	// command works in it, with its own copy of the caches.
+	Dir    string
+	Caches []Cache
+}
+
+// command wraps the command of the run so it works in its own directory of the
+// workspace, with its own copy of the caches.
+func (o ExecOptions) command() []string {
+	dir := WORKSPACE + "/" + o.Dir
+	script := fmt.Sprintf(`mkdir -p %s && cp -R %s/%s/. %s/ && cd %s || exit 1; %sexec "$@"`,
+		dir, INBOX, o.Dir, dir, dir, copyCaches(CACHE_DIR+"/"+o.Dir, o.Caches))
+	return append([]string{"sh", "-c", script, "sh"}, o.Cmd...)
+}
+
+// StreamExec executes the command of the tutorial in a running container of a
+// ModeExec pool, in a directory of its own cleaned afterwards. The output is
+// written like Stream does. The executions of a container can't be killed one
+// by one, so going over the output limit kills the whole container. Such a
+// container, like one of a failed or timed out run or one that can't be
+// cleaned, is removed and the pool replaces it once freed.
+func StreamExec(
+	ctx context.Context,
+	rt Runtime,
+	ctn string,
+	tutorial Tutorial,
+	files []File,
+	stdout, stderr io.Writer,
+) (RunResponse, error) {
+	// The container is killed and removed even once the run timed out
+	cleanupCtx := context.WithoutCancel(ctx)
+	var err error
+	defer func() {
+		if err != nil {
+			removeContainer(cleanupCtx, rt, ctn)
+		}
+	}()
+
+	dir := uuid.NewString()
+	runFiles := make([]File, 0, len(files))
+	for _, file := range files {
+		runFiles = append(runFiles, File{Name: dir + "/" + file.Name, Content: file.Content})
+	}
+	if err = rt.CopyFiles(ctx, ctn, runFiles); err != nil {
+		return RunResponse{}, err
+	}
+
+	execID, output, err := rt.Exec(ctx, ctn, ExecOptions{Cmd: tutorial.Command, Dir: dir, Caches: tutorial.Caches})
+	if err != nil {
+		return RunResponse{}, err
+	}
+	defer output.Close()
+
+	limit := &outputCap{remaining: tutorial.Limits.WithDefaults().OutputLimit, onExceed: func() {
+		if err := rt.Kill(cleanupCtx, ctn); err != nil {
+			log.Println(err)
+		}
+	}}
+	_, err = stdcopy.StdCopy(limit.writer(stdout), limit.writer(stderr), output)
+	if err != nil {
+		return RunResponse{}, err
+	}
+
+	if limit.exceeded {
+		removeContainer(cleanupCtx, rt, ctn)
+		return RunResponse{StatusCode: STATUS_KILLED, OutputTruncated: true}, nil
+	}
+
+	status, err := rt.ExecWait(ctx, execID)
+	if err != nil {
+		return RunResponse{}, err
+	}
+	// The run is over, a container left dirty is replaced rather than retried
+	if cleanErr := rt.CleanRun(cleanupCtx, ctn, dir); cleanErr != nil {
+		log.Printf("Failed to clean container %s after its run: %v", ctn, cleanErr)
+		removeContainer(cleanupCtx, rt, ctn)
+	}
+	return status, nil
+}
@@ -0,0 +1,181 @@
+package container_test
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"nexzap/internal/services/container"
+)
+
+func TestStreamExec_ReusesContainer(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		for _, name := range []string{"secret.txt", "/tmp/.cache/secret", container.WORKSPACE + "/notes.txt"} {
+			if _, ok := files[name]; ok {
+				return container.FakeResult{Stdout: "leaked " + name, StatusCode: 1}
+			}
+		}
+		return container.FakeResult{
+			Stdout: "ok " + files["main.go"],
+			// in the run directory, the HOME and the rest of the workspace
+			Written: map[string]string{
+				"secret.txt":                       "learner data",
+				"/tmp/.cache/secret":               "learner data",
+				container.WORKSPACE + "/notes.txt": "learner data",
+			},
+		}
+	})
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		return container.PoolPolicy{Min: 1, Max: 1}
+	})
+	defer pool.CleanAll(ctx, rt)
+	tutorial := container.Tutorial{Image: "gotest", Command: []string{"go", "test"}, Mode: container.ModeExec}
+	imagePool := pool.GetImagePool(ctx, rt, tutorial)
+
+	first := ""
+	for i, content := range []string{"package main", "package other"} {
+		ctn, err := imagePool.GetContainer(ctx, rt, container.Waiter{})
+		if err != nil {
+			t.Fatalf("GetContainer failed: %v", err)
+		}
+		if i == 0 {
+			first = ctn
+		} else if ctn != first {
+			t.Errorf("expected the container %s to be reused, got %s", first, ctn)
+		}
+
+		var stdout bytes.Buffer
+		files := []container.File{{Name: "main.go", Content: content}}
+		status, err := container.StreamExec(ctx, rt, ctn, tutorial, files, &stdout, &stdout)
+		if err != nil {
+			t.Fatalf("StreamExec failed: %v", err)
+		}
+		if status.StatusCode != 0 || stdout.String() != "ok "+content {
+			t.Errorf("run %d: expected ok, got %d %q", i, status.StatusCode, stdout.String())
+		}
+		if files := rt.Files(ctn); len(files) != 0 {
+			t.Errorf("run %d: expected the files of the run removed, got %v", i, files)
+		}
+		imagePool.FreeContainer(ctx, rt, ctn)
+	}
+}
+
+func TestStreamExec_CleanFailureReplacesContainer(t *testing.T) {
+	ctx := context.Background()
+	var rt *container.FakeRuntime
+	var ctn string
+	rt = container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		// dies right after the run, before it is cleaned
+		rt.Crash(ctn)
+		return container.FakeResult{Stdout: "ok"}
+	})
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		return container.PoolPolicy{Min: 1, Max: 1}
+	})
+	defer pool.CleanAll(ctx, rt)
+	tutorial := container.Tutorial{Image: "gotest", Command: []string{"go", "test"}, Mode: container.ModeExec}
+	imagePool := pool.GetImagePool(ctx, rt, tutorial)
+
+	var err error
+	if ctn, err = imagePool.GetContainer(ctx, rt, container.Waiter{}); err != nil {
+		t.Fatalf("GetContainer failed: %v", err)
+	}
+	var stdout bytes.Buffer
+	status, err := container.StreamExec(ctx, rt, ctn, tutorial, nil, &stdout, &stdout)
+	if err != nil || status.StatusCode != 0 || stdout.String() != "ok" {
+		t.Fatalf("expected the run to succeed, got %+v %q, %v", status, stdout.String(), err)
+	}
+	if slices.Contains(rt.Containers(), ctn) {
+		t.Errorf("expected the dirty container %s removed", ctn)
+	}
+	imagePool.FreeContainer(ctx, rt, ctn)
+	waitFor(t, "the container to be replaced", func() bool {
+		return len(imagePool.MinPool) == 1
+	})
+}
+
+func TestStreamExec_TimeoutRemovesContainer(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		<-release
+		return container.FakeResult{}
+	})
+	tutorial := container.Tutorial{Image: "gotest", Mode: container.ModeExec}
+	ctn, err := rt.Create(context.Background(), tutorial, nil)
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	rt.Start(context.Background(), ctn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var stdout bytes.Buffer
+	if _, err := container.StreamExec(ctx, rt, ctn, tutorial, nil, &stdout, &stdout); err == nil {
+		t.Fatal("expected the run to time out")
+	}
+	if len(rt.Containers()) != 0 {
+		t.Errorf("expected the container still running the learner code removed, got %v", rt.Containers())
+	}
+}
+
+func TestStreamExec_OutputLimitReplacesContainer(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		return container.FakeResult{Stdout: strings.Repeat("y\n", 100)}
+	})
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		return container.PoolPolicy{Min: 1, Max: 1}
+	})
+	defer pool.CleanAll(ctx, rt)
+	tutorial := container.Tutorial{Image: "yes", Limits: container.Limits{OutputLimit: 10}, Mode: container.ModeExec}
+	imagePool := pool.GetImagePool(ctx, rt, tutorial)
+
+	ctn, err := imagePool.GetContainer(ctx, rt, container.Waiter{})
+	if err != nil {
+		t.Fatalf("GetContainer failed: %v", err)
+	}
+	var stdout bytes.Buffer
+	status, err := container.StreamExec(ctx, rt, ctn, tutorial, nil, &stdout, &stdout)
+	if err != nil {
+		t.Fatalf("StreamExec failed: %v", err)
+	}
+	if !status.OutputTruncated || status.StatusCode != container.STATUS_KILLED || stdout.Len() != 10 {
+		t.Errorf("expected a killed run truncated to 10 bytes, got %+v with %d bytes", status, stdout.Len())
+	}
+	if rt.Kills != 1 {
+		t.Errorf("expected the container to be killed once, got %d", rt.Kills)
+	}
+
+	imagePool.FreeContainer(ctx, rt, ctn)
+	waitFor(t, "the container to be replaced", func() bool {
+		ctns := rt.Containers()
+		return len(ctns) == 1 && ctns[0] != ctn
+	})
+}
+
+func TestParseRunMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    container.RunMode
+		wantErr bool
+	}{
+		{"", container.ModeStart, false},
+		{"start", container.ModeStart, false},
+		{"exec", container.ModeExec, false},
+		{"fork", "", true},
+	}
+	for _, tt := range tests {
+		mode, err := container.ParseRunMode(tt.name)
+		if (err != nil) != tt.wantErr || mode != tt.mode {
+			t.Errorf("ParseRunMode(%q) = %q, %v", tt.name, mode, err)
+		}
+	}
+}
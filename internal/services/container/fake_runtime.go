@@ -0,0 +1,501 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// FakeResult is the outcome of a run in the FakeRuntime.
+type FakeResult struct {
+	Stdout     string
+	Stderr     string
+	StatusCode int64
+	// OOMKilled simulates a run killed for going over the memory limit
+	OOMKilled bool
+	// Written are the files created by the run in the workspace, or anywhere
+	// else for an absolute name like "/tmp/.cache/go"
+	Written map[string]string
+}
+
+// FakeHandler simulates the command of a container. It receives the tutorial
+// and the content of the workspace, indexed by file name.
+type FakeHandler func(tutorial Tutorial, files map[string]string) FakeResult
+
+// FakeRuntime is an in-process Runtime to exercise the pool and the services
+// without a Docker daemon. Like a real container, the workspace of a fake
+// container persists between runs until it is removed. The containers of
+// ModeExec run the handler on each execution instead of on start.
+type FakeRuntime struct {
+	sync.Mutex
+	Handler    FakeHandler
+	containers map[string]*fakeContainer
+	nextID     int
+	// images by reference, with the number of builds
+	images map[string]string
+	Builds int
+	// Kills counts the calls to Kill
+	Kills int
+	// status of the executions by id
+	execs    map[string]RunResponse
+	nextExec int
+	// down simulates a host not answering, see SetDown
+	down bool
+	// last heartbeat by instance
+	heartbeats map[string]time.Time
+}
+
+// errFakeDown is returned by every call while the fake is down
+var errFakeDown = errors.New("cannot connect to the fake daemon")
+
+// SetDown simulates the host of the runtime going down or coming back. While
+// down, every call fails and the containers are left as they are.
+func (f *FakeRuntime) SetDown(down bool) {
+	f.Lock()
+	defer f.Unlock()
+	f.down = down
+}
+
+// Ping fails while the fake is down.
+func (f *FakeRuntime) Ping(ctx context.Context) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.down {
+		return errFakeDown
+	}
+	return nil
+}
+
+type fakeContainer struct {
+	tutorial Tutorial
+	labels   map[string]string
+	files    map[string]string
+	logs     bytes.Buffer
+	status   RunResponse
+	done     chan struct{}
+	killed   bool
+	// running tells a container of ModeExec has been started
+	running bool
+	// crashed simulates a container that died on its own
+	crashed bool
+}
+
+// NewFakeRuntime creates a fake runtime running the handler on each start.
+func NewFakeRuntime(handler FakeHandler) *FakeRuntime {
+	return &FakeRuntime{
+		Handler:    handler,
+		containers: make(map[string]*fakeContainer),
+		images:     make(map[string]string),
+		execs:      make(map[string]RunResponse),
+		heartbeats: make(map[string]time.Time),
+	}
+}
+
+// Containers returns the ids of the containers not removed yet.
+func (f *FakeRuntime) Containers() []string {
+	f.Lock()
+	defer f.Unlock()
+	return slices.Sorted(maps.Keys(f.containers))
+}
+
+// Files returns the names of the files of a container, with the directory of
+// their run for ModeExec.
+func (f *FakeRuntime) Files(id string) []string {
+	f.Lock()
+	defer f.Unlock()
+	ctn, ok := f.containers[id]
+	if !ok {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(ctn.files))
+}
+
+func (f *FakeRuntime) get(id string) (*fakeContainer, error) {
+	if f.down {
+		return nil, errFakeDown
+	}
+	ctn, ok := f.containers[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return ctn, nil
+}
+
+func (f *FakeRuntime) Create(ctx context.Context, tutorial Tutorial, labels map[string]string) (string, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.down {
+		return "", errFakeDown
+	}
+	// Like Docker, a pinned image must exist, the others are never pulled
+	if _, err := f.imageID(tutorial.Digest); tutorial.Digest != "" && err != nil {
+		return "", err
+	}
+	f.nextID++
+	id := fmt.Sprintf("fake-%d", f.nextID)
+	f.containers[id] = &fakeContainer{
+		tutorial: tutorial,
+		labels:   maps.Clone(labels),
+		files:    make(map[string]string),
+	}
+	return id, nil
+}
+
+func (f *FakeRuntime) CopyFiles(ctx context.Context, id string, files []File) error {
+	f.Lock()
+	defer f.Unlock()
+	ctn, err := f.get(id)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		ctn.files[file.Name] = file.Content
+	}
+	return nil
+}
+
+func (f *FakeRuntime) Start(ctx context.Context, id string) error {
+	f.Lock()
+	defer f.Unlock()
+	ctn, err := f.get(id)
+	if err != nil {
+		return err
+	}
+	// Idles until the executions
+	if ctn.tutorial.Mode == ModeExec {
+		ctn.running = true
+		return nil
+	}
+	done := make(chan struct{})
+	ctn.done = done
+	ctn.killed = false
+	tutorial := ctn.tutorial
+	files := maps.Clone(ctn.files)
+	go func() {
+		result := f.Handler(tutorial, files)
+		f.Lock()
+		defer f.Unlock()
+		maps.Copy(ctn.files, result.Written)
+		ctn.logs.Reset()
+		io.WriteString(stdcopy.NewStdWriter(&ctn.logs, stdcopy.Stdout), result.Stdout)
+		io.WriteString(stdcopy.NewStdWriter(&ctn.logs, stdcopy.Stderr), result.Stderr)
+		ctn.status = RunResponse{StatusCode: result.StatusCode, OOMKilled: result.OOMKilled}
+		if ctn.killed || result.OOMKilled {
+			ctn.status.StatusCode = STATUS_KILLED
+		}
+		close(done)
+	}()
+	return nil
+}
+
+func (f *FakeRuntime) Wait(ctx context.Context, id string) (RunResponse, error) {
+	f.Lock()
+	ctn, err := f.get(id)
+	var done chan struct{}
+	if err == nil {
+		done = ctn.done
+	}
+	f.Unlock()
+	if err != nil {
+		return RunResponse{}, err
+	}
+	if done == nil {
+		return RunResponse{}, nil
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return RunResponse{}, ctx.Err()
+	}
+	f.Lock()
+	defer f.Unlock()
+	return ctn.status, nil
+}
+
+// Kill marks a running container as killed, its status becomes STATUS_KILLED
+// once the handler returns.
+func (f *FakeRuntime) Kill(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.Lock()
+	defer f.Unlock()
+	ctn, err := f.get(id)
+	if err != nil {
+		return err
+	}
+	f.Kills++
+	if ctn.done == nil {
+		return nil
+	}
+	select {
+	case <-ctn.done:
+	default:
+		ctn.killed = true
+	}
+	return nil
+}
+
+// Logs returns the logs of the last run. With Follow, it first waits for the
+// run to finish as the fake does not produce its output progressively.
+func (f *FakeRuntime) Logs(ctx context.Context, id string, opts LogsOptions) (io.ReadCloser, error) {
+	if opts.Follow {
+		if _, err := f.Wait(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	f.Lock()
+	defer f.Unlock()
+	ctn, err := f.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(bytes.Clone(ctn.logs.Bytes()))), nil
+}
+
+// Remove does nothing once the context is done, like the Docker API.
+func (f *FakeRuntime) Remove(ctx context.Context, id string) {
+	f.Lock()
+	defer f.Unlock()
+	if f.down || ctx.Err() != nil {
+		return
+	}
+	delete(f.containers, id)
+}
+
+// Inspect returns the status Docker would give to the container.
+func (f *FakeRuntime) Inspect(ctx context.Context, id string) (ContainerState, error) {
+	f.Lock()
+	defer f.Unlock()
+	ctn, err := f.get(id)
+	if err != nil {
+		return ContainerState{}, err
+	}
+	if ctn.crashed {
+		return ContainerState{Status: "dead"}, nil
+	}
+	if ctn.running {
+		return ContainerState{Status: "running"}, nil
+	}
+	if ctn.done == nil {
+		return ContainerState{Status: "created"}, nil
+	}
+	select {
+	case <-ctn.done:
+		return ContainerState{Status: "exited"}, nil
+	default:
+		return ContainerState{Status: "running"}, nil
+	}
+}
+
+// Crash simulates a container broken on its own, like when the daemon
+// restarts, its status becomes dead.
+func (f *FakeRuntime) Crash(id string) {
+	f.Lock()
+	defer f.Unlock()
+	if ctn, ok := f.containers[id]; ok {
+		ctn.crashed = true
+	}
+}
+
+func (f *FakeRuntime) List(ctx context.Context, labels map[string]string) ([]ContainerInfo, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.down {
+		return nil, errFakeDown
+	}
+	infos := []ContainerInfo{}
+	for _, id := range slices.Sorted(maps.Keys(f.containers)) {
+		ctn := f.containers[id]
+		matches := true
+		for key, value := range labels {
+			matches = matches && ctn.labels[key] == value
+		}
+		if matches {
+			infos = append(infos, ContainerInfo{ID: id, Labels: maps.Clone(ctn.labels)})
+		}
+	}
+	return infos, nil
+}
+
+// Exec runs the handler right away on the files of the directory of the run,
+// the output being returned once it is done, unless the context is done first.
+func (f *FakeRuntime) Exec(ctx context.Context, id string, opts ExecOptions) (string, io.ReadCloser, error) {
+	f.Lock()
+	ctn, err := f.get(id)
+	if err != nil {
+		f.Unlock()
+		return "", nil, err
+	}
+	prefix := opts.Dir + "/"
+	files := map[string]string{}
+	for name, content := range ctn.files {
+		if name, ok := strings.CutPrefix(name, prefix); ok {
+			files[name] = content
+		} else if strings.HasPrefix(name, "/") {
+			files[name] = content
+		}
+	}
+	tutorial := ctn.tutorial
+	tutorial.Command = opts.Cmd
+	f.Unlock()
+
+	results := make(chan FakeResult, 1)
+	go func() { results <- f.Handler(tutorial, files) }()
+	var result FakeResult
+	select {
+	case result = <-results:
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	}
+	f.Lock()
+	defer f.Unlock()
+	for name, content := range result.Written {
+		if !strings.HasPrefix(name, "/") {
+			name = prefix + name
+		}
+		ctn.files[name] = content
+	}
+	var logs bytes.Buffer
+	io.WriteString(stdcopy.NewStdWriter(&logs, stdcopy.Stdout), result.Stdout)
+	io.WriteString(stdcopy.NewStdWriter(&logs, stdcopy.Stderr), result.Stderr)
+	// Told apart by the kills counted in the cgroup, see DockerRuntime.Exec
+	status := RunResponse{StatusCode: result.StatusCode, OOMKilled: result.OOMKilled}
+	if result.OOMKilled {
+		status.StatusCode = STATUS_KILLED
+	}
+	f.nextExec++
+	execID := fmt.Sprintf("fake-exec-%d", f.nextExec)
+	f.execs[execID] = status
+	return execID, io.NopCloser(&logs), nil
+}
+
+func (f *FakeRuntime) ExecWait(ctx context.Context, execID string) (RunResponse, error) {
+	f.Lock()
+	defer f.Unlock()
+	status, ok := f.execs[execID]
+	if !ok {
+		return RunResponse{}, fmt.Errorf("no such exec: %s", execID)
+	}
+	delete(f.execs, execID)
+	return status, nil
+}
+
+// CleanRun removes the files of the run, and like Docker everything written
+// in the workspace and /tmp.
+func (f *FakeRuntime) CleanRun(ctx context.Context, id string, dir string) error {
+	f.Lock()
+	defer f.Unlock()
+	ctn, err := f.get(id)
+	if err != nil {
+		return err
+	}
+	// like Docker, nothing can be executed in a dead container
+	if ctn.crashed {
+		return fmt.Errorf("container %s is not running", id)
+	}
+	for name := range ctn.files {
+		if strings.HasPrefix(name, dir+"/") || strings.HasPrefix(name, WORKSPACE+"/") || strings.HasPrefix(name, "/tmp/") {
+			delete(ctn.files, name)
+		}
+	}
+	return nil
+}
+
+func (f *FakeRuntime) Heartbeat(ctx context.Context, instance string, at time.Time) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.down {
+		return errFakeDown
+	}
+	f.heartbeats[instance] = at
+	return nil
+}
+
+func (f *FakeRuntime) Instances(ctx context.Context) (map[string]time.Time, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.down {
+		return nil, errFakeDown
+	}
+	return maps.Clone(f.heartbeats), nil
+}
+
+func (f *FakeRuntime) ForgetInstance(ctx context.Context, instance string) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.down {
+		return errFakeDown
+	}
+	delete(f.heartbeats, instance)
+	return nil
+}
+
+// AddImage makes an image available as if it was pulled or built by hand.
+func (f *FakeRuntime) AddImage(ref string) string {
+	f.Lock()
+	defer f.Unlock()
+	id := fmt.Sprintf("sha256:fake-%d", len(f.images)+1)
+	f.images[ref] = id
+	return id
+}
+
+func (f *FakeRuntime) BuildImage(ctx context.Context, files []File, tags []string) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.down {
+		return errFakeDown
+	}
+	if !slices.ContainsFunc(files, func(file File) bool { return file.Name == "Dockerfile" }) {
+		return fmt.Errorf("no Dockerfile in the build context")
+	}
+	f.Builds++
+	id := fmt.Sprintf("sha256:fake-build-%d", f.Builds)
+	for _, tag := range tags {
+		f.images[tag] = id
+	}
+	return nil
+}
+
+func (f *FakeRuntime) ImageID(ctx context.Context, ref string) (string, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.down {
+		return "", errFakeDown
+	}
+	return f.imageID(ref)
+}
+
+// imageID resolves a reference or an id, like Docker does.
+func (f *FakeRuntime) imageID(ref string) (string, error) {
+	if id, ok := f.images[ref]; ok {
+		return id, nil
+	}
+	if slices.Contains(slices.Collect(maps.Values(f.images)), ref) {
+		return ref, nil
+	}
+	return "", fmt.Errorf("no such image: %s", ref)
+}
+
+func (f *FakeRuntime) TagImage(ctx context.Context, source, target string) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.down {
+		return errFakeDown
+	}
+	id, err := f.imageID(source)
+	if err != nil {
+		return err
+	}
+	f.images[target] = id
+	return nil
+}
@@ -0,0 +1,206 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// time between two sweeps of the free containers
+	HEALTH_INTERVAL = 30 * time.Second
+	// failures in a row before an image, or a node, is considered broken
+	HEALTH_FAILURES = 3
+	// delay before using a broken image or node again, doubled at each new
+	// failure up to HEALTH_MAX_BACKOFF
+	HEALTH_BACKOFF     = time.Second
+	HEALTH_MAX_BACKOFF = time.Minute
+)
+
+// ErrImageUnhealthy is returned instead of a container while the containers
+// of the image keep dying.
+var ErrImageUnhealthy = errors.New("The sandboxes of this exercise are failing, please try again later")
+
+// ContainerState is the state of a container returned by Runtime.Inspect.
+type ContainerState struct {
+	// Status as named by Docker: created, running, exited, dead...
+	Status string
+}
+
+// ready tells whether a free container can be handed out. The containers of
+// ModeExec must be running, the others not started yet, or stopped when
+// ResetNone reuses them.
+func (s ContainerState) ready(mode RunMode) bool {
+	if mode == ModeExec {
+		return s.Status == "running"
+	}
+	return s.Status == "created" || s.Status == "exited"
+}
+
+// backoff counts the failures in a row of the containers of an image, or of a
+// worker node, and delays the next attempts once they pile up.
+type backoff struct {
+	mu       sync.Mutex
+	failures int
+	// no container is created before
+	retryAt time.Time
+}
+
+// failed records a container that could not be created or was found dead, or
+// a node that did not answer.
+func (h *backoff) failed() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures++
+	if h.failures >= HEALTH_FAILURES {
+		backoff := HEALTH_BACKOFF
+		for range h.failures - HEALTH_FAILURES {
+			backoff = min(2*backoff, HEALTH_MAX_BACKOFF)
+		}
+		h.retryAt = time.Now().Add(backoff)
+	}
+}
+
+// succeeded records a container found ready, or a node that answered.
+func (h *backoff) succeeded() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures = 0
+	h.retryAt = time.Time{}
+}
+
+// broken tells whether too many failures happened in a row.
+func (h *backoff) broken() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.failures >= HEALTH_FAILURES
+}
+
+// delay returns the time to wait before the next attempt.
+func (h *backoff) delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return max(time.Until(h.retryAt), 0)
+}
+
+// check tells whether a container can be handed out, recording the outcome.
+// A container that can't be inspected is as good as dead.
+func (lp *ImagePool) check(ctx context.Context, rt Runtime, ctn string) bool {
+	state, err := rt.Inspect(ctx, ctn)
+	if err == nil && state.ready(lp.language.Mode) {
+		lp.health.succeeded()
+		return true
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Failed to inspect container of %s: %v", lp.language.Image, err)
+	}
+	// A node down is no fault of the image, its containers are created elsewhere
+	if !errors.Is(err, ErrNodeDown) {
+		lp.health.failed()
+	}
+	containersUnhealthy.WithLabelValues(lp.language.Image).Inc()
+	return false
+}
+
+// replace removes a container and creates its replacement in the background.
+func (lp *ImagePool) replace(ctx context.Context, rt Runtime, ctn string) {
+	go func() {
+		// unless the run already removed it
+		if isOwned(ctn) {
+			removeContainer(ctx, rt, ctn)
+		}
+		lp.recreate(ctx, rt)
+	}()
+}
+
+// recreate creates a container and puts it in the pool. A failed creation is
+// retried with the backoff of the image until the pool is closed, so the pool
+// keeps its size, or until the image is broken: the pool is then degraded and
+// the next sweep tries again.
+func (lp *ImagePool) recreate(ctx context.Context, rt Runtime) {
+	for {
+		time.Sleep(lp.health.delay())
+		if lp.isClosed() {
+			return
+		}
+		id, err := createContainer(ctx, rt, lp.language, PoolReplacement)
+		if err == nil {
+			lp.release(ctx, rt, id)
+			return
+		}
+		log.Printf("Failed to recreate container of %s: %v", lp.language.Image, err)
+		lp.health.failed()
+		if lp.health.broken() {
+			log.Printf("Image %s is broken, its pool misses a container until the next health check", lp.language.Image)
+			lp.mu.Lock()
+			lp.missing++
+			lp.mu.Unlock()
+			return
+		}
+	}
+}
+
+// sweep checks the free containers and replaces the dead ones, and the ones a
+// broken image left missing. The containers are taken out of the pool while
+// checked, like for a run.
+func (lp *ImagePool) sweep(ctx context.Context, rt Runtime) {
+	lp.mu.Lock()
+	if lp.closed {
+		lp.mu.Unlock()
+		return
+	}
+	for range lp.missing {
+		go lp.recreate(ctx, rt)
+	}
+	lp.missing = 0
+	free := []string{}
+	for _, ch := range []chan string{lp.MinPool, lp.ExtendedPool} {
+	drain:
+		for {
+			select {
+			case c := <-ch:
+				free = append(free, c)
+			default:
+				break drain
+			}
+		}
+	}
+	lp.mu.Unlock()
+
+	for _, ctn := range free {
+		if lp.check(ctx, rt, ctn) {
+			lp.release(ctx, rt, ctn)
+		} else {
+			lp.replace(ctx, rt, ctn)
+		}
+	}
+}
+
+// CheckHealth sweeps the free containers of every image once.
+func (p *Pool) CheckHealth(ctx context.Context, rt Runtime) {
+	p.Lock()
+	languages := make([]*ImagePool, 0, len(p.pool))
+	for _, language := range p.pool {
+		languages = append(languages, language)
+	}
+	p.Unlock()
+	for _, language := range languages {
+		language.sweep(ctx, rt)
+	}
+}
+
+// RunHealthChecks sweeps the pools every interval until the context is done.
+func (p *Pool) RunHealthChecks(ctx context.Context, rt Runtime, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.CheckHealth(ctx, rt)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
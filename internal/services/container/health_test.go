@@ -0,0 +1,109 @@
+package container_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"nexzap/internal/services/container"
+)
+
+func TestGetContainer_ReplacesDeadContainers(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		return container.PoolPolicy{Min: 2, Max: 1}
+	})
+	defer pool.CleanAll(ctx, rt)
+	imagePool := pool.GetImagePool(ctx, rt, container.Tutorial{Image: "gotest"})
+
+	// One dies, the other is removed behind the back of the pool
+	broken := rt.Containers()
+	rt.Crash(broken[0])
+	rt.Remove(ctx, broken[1])
+
+	ctn, err := imagePool.GetContainer(ctx, rt, container.Waiter{})
+	if err != nil {
+		t.Fatalf("GetContainer failed: %v", err)
+	}
+	if slices.Contains(broken, ctn) {
+		t.Errorf("expected a healthy container, got the broken %s", ctn)
+	}
+	if state, err := rt.Inspect(ctx, ctn); err != nil || state.Status != "created" {
+		t.Errorf("expected a created container, got %+v, %v", state, err)
+	}
+	imagePool.FreeContainer(ctx, rt, ctn)
+	waitFor(t, "the broken containers to be replaced", func() bool {
+		alive := rt.Containers()
+		return len(imagePool.MinPool) == 2 && !slices.Contains(alive, broken[0])
+	})
+}
+
+func TestGetContainer_BrokenImage(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		return container.PoolPolicy{Min: container.HEALTH_FAILURES, Max: 1}
+	})
+	defer pool.CleanAll(ctx, rt)
+	imagePool := pool.GetImagePool(ctx, rt, container.Tutorial{Image: "broken", Mode: container.ModeExec})
+
+	for _, ctn := range rt.Containers() {
+		rt.Crash(ctn)
+	}
+	_, err := imagePool.GetContainer(ctx, rt, container.Waiter{})
+	if !errors.Is(err, container.ErrImageUnhealthy) {
+		t.Fatalf("expected the image to be unhealthy, got %v", err)
+	}
+}
+
+func TestRecreate_StopsOnBrokenImage(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		return container.PoolPolicy{Min: 1, Max: 1}
+	})
+	defer pool.CleanAll(ctx, rt)
+	pool.ExportMetrics()
+
+	// The creations keep failing, the pool gives up and reports it
+	rt.SetDown(true)
+	imagePool := pool.GetImagePool(ctx, rt, container.Tutorial{Image: "unbuildable"})
+	waitFor(t, "the pool to be degraded", func() bool {
+		return strings.Contains(scrape(), `nexzap_pool_missing_containers{image="unbuildable"} 1`+"\n")
+	})
+
+	// The sweep tries again once the backoff is over
+	rt.SetDown(false)
+	time.Sleep(container.HEALTH_BACKOFF)
+	pool.CheckHealth(ctx, rt)
+	waitFor(t, "the missing container to be recreated", func() bool {
+		return len(imagePool.MinPool) == 1
+	})
+}
+
+func TestCheckHealth_SweepsFreeContainers(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		return container.PoolPolicy{Min: 2, Max: 1}
+	})
+	defer pool.CleanAll(ctx, rt)
+	imagePool := pool.GetImagePool(ctx, rt, container.Tutorial{Image: "gotest", Mode: container.ModeExec})
+
+	healthy, dead := rt.Containers()[0], rt.Containers()[1]
+	rt.Crash(dead)
+	pool.CheckHealth(ctx, rt)
+	waitFor(t, "the dead container to be replaced", func() bool {
+		alive := rt.Containers()
+		return len(alive) == 2 && slices.Contains(alive, healthy) && !slices.Contains(alive, dead) &&
+			len(imagePool.MinPool) == 2
+	})
+}
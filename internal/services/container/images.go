@@ -0,0 +1,34 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// ImageBuilder builds and resolves the images running the submissions.
+type ImageBuilder interface {
+	// BuildImage builds an image from the files of the build context and tags it.
+	BuildImage(ctx context.Context, files []File, tags []string) error
+	// ImageID returns the content-addressed id of an image. Errors if it does not exist.
+	ImageID(ctx context.Context, ref string) (string, error)
+}
+
+// readBuildOutput consumes the progress of a build and returns the error reported by the daemon.
+func readBuildOutput(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package container
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Labels set on every sandbox container, so they can be told apart from
+// anything else running on the host.
+const (
+	LABEL_APP        = "nexzap.app"
+	LABEL_IMAGE      = "nexzap.image"
+	LABEL_POOL       = "nexzap.pool"
+	LABEL_CREATED_AT = "nexzap.created-at"
+	LABEL_INSTANCE   = "nexzap.instance"
+	// time of a heartbeat, see Runtime.Heartbeat
+	LABEL_HEARTBEAT = "nexzap.heartbeat"
+)
+
+// App is the value of LABEL_APP. Commands running sandboxes next to the server,
+// like validate, use their own so the reaper of the server leaves them alone.
+var App = "nexzap"
+
+// PoolKind tells for which part of the pool a container was created.
+type PoolKind string
+
+const (
+	PoolMin      PoolKind = "min"
+	PoolExtended PoolKind = "extended"
+	// container replacing a used one, see ResetRecreate
+	PoolReplacement PoolKind = "replacement"
+)
+
+// Instance identifies the running server in the labels of its containers.
+var Instance = uuid.NewString()
+
+// Labels returns the labels of a container created now by this instance.
+func Labels(image string, kind PoolKind) map[string]string {
+	return map[string]string{
+		LABEL_APP:        App,
+		LABEL_IMAGE:      image,
+		LABEL_POOL:       string(kind),
+		LABEL_CREATED_AT: time.Now().UTC().Format(time.RFC3339),
+		LABEL_INSTANCE:   Instance,
+	}
+}
+
+// owned holds the containers created and not removed yet by this instance.
+// Any other labelled container is an orphan for the reaper.
+var owned = struct {
+	sync.Mutex
+	ids map[string]struct{}
+}{ids: make(map[string]struct{})}
+
+func track(id string) {
+	owned.Lock()
+	defer owned.Unlock()
+	owned.ids[id] = struct{}{}
+}
+
+func untrack(id string) {
+	owned.Lock()
+	defer owned.Unlock()
+	delete(owned.ids, id)
+}
+
+func isOwned(id string) bool {
+	owned.Lock()
+	defer owned.Unlock()
+	_, ok := owned.ids[id]
+	return ok
+}
@@ -0,0 +1,107 @@
+package container
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// memory of a container when not set by the sheet
+	DEFAULT_MEMORY = 512 * 1024 * 1024
+	// cpus of a container when not set by the sheet
+	DEFAULT_CPUS = 1.0
+	// time before a run is cancelled when not set by the sheet
+	DEFAULT_TIMEOUT = 30 * time.Second
+	// number of processes in a container when not set by the sheet
+	DEFAULT_PIDS_LIMIT = 128
+	// size of the output kept when not set by the sheet
+	DEFAULT_OUTPUT_LIMIT = 1024 * 1024
+	// size of the logs kept by Docker, as a multiple of the output limit
+	LOG_SIZE_FACTOR = 4
+)
+
+// Limits holds the resources allowed to a container. Zero values mean the default.
+type Limits struct {
+	// Memory in bytes
+	Memory int64
+	CPUs   float64
+	// Timeout of a single run
+	Timeout   time.Duration
+	PidsLimit int64
+	// OutputLimit in bytes, stdout and stderr combined
+	OutputLimit int64
+}
+
+// WithDefaults returns the limits with the unset values replaced by the defaults.
+func (l Limits) WithDefaults() Limits {
+	if l.Memory <= 0 {
+		l.Memory = DEFAULT_MEMORY
+	}
+	if l.CPUs <= 0 {
+		l.CPUs = DEFAULT_CPUS
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DEFAULT_TIMEOUT
+	}
+	if l.PidsLimit <= 0 {
+		l.PidsLimit = DEFAULT_PIDS_LIMIT
+	}
+	if l.OutputLimit <= 0 {
+		l.OutputLimit = DEFAULT_OUTPUT_LIMIT
+	}
+	return l
+}
+
+// created returns the limits applied when creating a container, the timeout
+// being up to the caller of each run.
+func (l Limits) created() Limits {
+	l = l.WithDefaults()
+	l.Timeout = 0
+	return l
+}
+
+func (l Limits) String() string {
+	return fmt.Sprintf("mem=%d,cpus=%g,timeout=%s,pids=%d,output=%d",
+		l.Memory, l.CPUs, l.Timeout, l.PidsLimit, l.OutputLimit)
+}
+
+// outputCap counts the bytes written on stdout and stderr together.
+type outputCap struct {
+	remaining int64
+	exceeded  bool
+	// onExceed is called once, on the first write going over the cap
+	onExceed func()
+}
+
+func (c *outputCap) writer(w io.Writer) *limitWriter {
+	return &limitWriter{w: w, shared: c}
+}
+
+// limitWriter writes to w until the shared cap is reached and silently
+// discards the rest.
+type limitWriter struct {
+	w      io.Writer
+	shared *outputCap
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if int64(len(p)) > l.shared.remaining {
+		p = p[:l.shared.remaining]
+		if !l.shared.exceeded {
+			l.shared.exceeded = true
+			if l.shared.onExceed != nil {
+				l.shared.onExceed()
+			}
+		}
+	}
+	if len(p) > 0 {
+		written, err := l.w.Write(p)
+		l.shared.remaining -= int64(written)
+		if err != nil {
+			return written, err
+		}
+	}
+	return n, nil
+}
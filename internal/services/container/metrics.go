@@ -0,0 +1,137 @@
+package container
+
+import (
+	"errors"
+	"nexzap/internal/metrics"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	containersCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "nexzap_containers_created_total",
+		Help: "Containers created, by image.",
+	}, []string{"image"})
+	containersRemoved = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "nexzap_containers_removed_total",
+		Help: "Containers removed.",
+	})
+	containersUnhealthy = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "nexzap_containers_unhealthy_total",
+		Help: "Free containers found dead or not inspectable, by image.",
+	}, []string{"image"})
+	containerWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "nexzap_get_container_wait_seconds",
+		Help:    "Time waited for a container, by image and outcome.",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"image", "outcome"})
+	poolTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "nexzap_pool_timeouts_total",
+		Help: "Idle timeouts fired, by image and kind of timeout.",
+	}, []string{"image", "kind"})
+)
+
+// exportedPools are the pools whose state is exposed on the metrics endpoint.
+var exportedPools = struct {
+	sync.Mutex
+	pools []*Pool
+}{}
+
+var (
+	poolFreeDesc = prometheus.NewDesc(
+		"nexzap_pool_free_containers",
+		"Free containers, by image and pool.",
+		[]string{"image", "pool"}, nil,
+	)
+	poolSlotsDesc = prometheus.NewDesc(
+		"nexzap_pool_extension_slots_used",
+		"Extended containers deployed or being created, by image.",
+		[]string{"image"}, nil,
+	)
+	poolQueuedDesc = prometheus.NewDesc(
+		"nexzap_pool_queued_submissions",
+		"Submissions waiting for a container, by image.",
+		[]string{"image"}, nil,
+	)
+	poolMissingDesc = prometheus.NewDesc(
+		"nexzap_pool_missing_containers",
+		"Containers of the pools not recreated since their image broke, by image.",
+		[]string{"image"}, nil,
+	)
+)
+
+// poolCollector reads the state of the exported pools at each scrape.
+type poolCollector struct{}
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolFreeDesc
+	ch <- poolSlotsDesc
+	ch <- poolQueuedDesc
+	ch <- poolMissingDesc
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for image, stats := range collectStats() {
+		ch <- prometheus.MustNewConstMetric(poolFreeDesc, prometheus.GaugeValue, float64(stats.min), image, "min")
+		ch <- prometheus.MustNewConstMetric(poolFreeDesc, prometheus.GaugeValue, float64(stats.extended), image, "extended")
+		ch <- prometheus.MustNewConstMetric(poolSlotsDesc, prometheus.GaugeValue, float64(stats.slots), image)
+		ch <- prometheus.MustNewConstMetric(poolQueuedDesc, prometheus.GaugeValue, float64(stats.queued), image)
+		ch <- prometheus.MustNewConstMetric(poolMissingDesc, prometheus.GaugeValue, float64(stats.missing), image)
+	}
+}
+
+func init() {
+	prometheus.MustRegister(poolCollector{})
+}
+
+// ExportMetrics exposes the state of the pool on the metrics endpoint.
+func (p *Pool) ExportMetrics() {
+	exportedPools.Lock()
+	defer exportedPools.Unlock()
+	exportedPools.pools = append(exportedPools.pools, p)
+}
+
+type poolStats struct {
+	min, extended, slots, queued, missing int
+}
+
+// collectStats sums the state of the exported pools per image.
+func collectStats() map[string]poolStats {
+	exportedPools.Lock()
+	defer exportedPools.Unlock()
+	result := map[string]poolStats{}
+	for _, p := range exportedPools.pools {
+		p.Lock()
+		for _, lp := range p.pool {
+			lp.mu.Lock()
+			if !lp.closed {
+				stats := result[lp.language.Image]
+				stats.min += len(lp.MinPool)
+				stats.extended += len(lp.ExtendedPool)
+				stats.slots += len(lp.extensionSlots)
+				stats.queued += lp.queue.size
+				stats.missing += lp.missing
+				result[lp.language.Image] = stats
+			}
+			lp.mu.Unlock()
+		}
+		p.Unlock()
+	}
+	return result
+}
+
+// observeWait records the time waited by GetContainer.
+func observeWait(image string, start time.Time, err error) {
+	outcome := "ok"
+	var busy *BusyError
+	switch {
+	case errors.As(err, &busy):
+		outcome = "busy"
+	case err != nil:
+		outcome = "error"
+	}
+	containerWait.WithLabelValues(image, outcome).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,470 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// PIN_REPOSITORY tags on every node the image pinned by a tutorial. The id of
+// an image built on several nodes differs from one to another, so the tutorials
+// pin the id of the first node and each node tags its own image with it.
+const PIN_REPOSITORY = "nexzap-pin"
+
+// NODE_PING_TIMEOUT is the time a node has to answer before being considered down
+const NODE_PING_TIMEOUT = 5 * time.Second
+
+// ErrNodeDown is returned for the containers of a worker node that does not answer.
+var ErrNodeDown = errors.New("worker node down")
+
+// NodeConfig describes a Docker host running the submissions.
+type NodeConfig struct {
+	// Name prefixes the ids of the containers of the node
+	Name string `toml:"name"`
+	// Host of the Docker API, e.g. "unix:///var/run/docker.sock" or "tcp://10.0.0.2:2376"
+	Host string `toml:"host"`
+	// TLS is the directory holding the ca.pem, cert.pem and key.pem of the
+	// client, plain TCP when empty
+	TLS string `toml:"tls"`
+	// Capacity is the share of the containers of the node relative to the
+	// others, 1 when unset
+	Capacity int `toml:"capacity"`
+}
+
+// LoadNodes reads the worker nodes of a toml file listing [[node]] tables.
+func LoadNodes(path string) ([]NodeConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		Nodes []NodeConfig `toml:"node"`
+	}
+	if err := toml.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+	if len(config.Nodes) == 0 {
+		return nil, fmt.Errorf("no [[node]] in %s", path)
+	}
+	names := map[string]bool{}
+	for i, node := range config.Nodes {
+		if node.Name == "" || strings.Contains(node.Name, "/") || names[node.Name] {
+			return nil, fmt.Errorf("node %d: the name must be set, unique and without /", i+1)
+		}
+		names[node.Name] = true
+		if node.Host == "" {
+			return nil, fmt.Errorf("node %s: host is not set", node.Name)
+		}
+		if node.Capacity < 0 {
+			return nil, fmt.Errorf("node %s: capacity must be positive", node.Name)
+		}
+		if node.Capacity == 0 {
+			config.Nodes[i].Capacity = 1
+		}
+	}
+	return config.Nodes, nil
+}
+
+// NodeRuntime is the runtime of a worker node. Besides running the containers,
+// it builds the images and tags them for the other nodes.
+type NodeRuntime interface {
+	Runtime
+	ImageBuilder
+	// TagImage adds the target reference to the source image.
+	TagImage(ctx context.Context, source, target string) error
+	// Ping checks the node answers.
+	Ping(ctx context.Context) error
+}
+
+// Node is a worker node of a MultiRuntime.
+type Node struct {
+	Name     string
+	Runtime  NodeRuntime
+	Capacity int
+}
+
+// node tracks the load and the health of a worker node.
+type node struct {
+	Node
+	health *backoff
+	// ids of the containers created on the node and not removed yet, the
+	// orphans found by the reaper are not counted
+	containers map[string]struct{}
+}
+
+// MultiRuntime spreads the containers on several worker nodes. Each container
+// is created on the node with the least load for its capacity, skipping the
+// nodes that stopped answering, and its id is prefixed with the name of its
+// node so the other calls reach it.
+type MultiRuntime struct {
+	mu    sync.Mutex
+	nodes []*node
+}
+
+// NewMultiRuntime creates a runtime spreading the containers on the nodes.
+func NewMultiRuntime(nodes []Node) *MultiRuntime {
+	m := &MultiRuntime{}
+	for _, n := range nodes {
+		n.Capacity = max(n.Capacity, 1)
+		m.nodes = append(m.nodes, &node{Node: n, health: &backoff{}, containers: map[string]struct{}{}})
+	}
+	return m
+}
+
+// NewMultiRuntimeFromConfig connects to the Docker API of each node.
+func NewMultiRuntimeFromConfig(configs []NodeConfig) (*MultiRuntime, error) {
+	nodes := []Node{}
+	for _, config := range configs {
+		rt, err := NewDockerRuntimeForNode(config)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %v", config.Name, err)
+		}
+		nodes = append(nodes, Node{Name: config.Name, Runtime: rt, Capacity: config.Capacity})
+	}
+	return NewMultiRuntime(nodes), nil
+}
+
+// Engine runs the containers and builds their images.
+type Engine interface {
+	Runtime
+	ImageBuilder
+}
+
+// NewEngineFromEnv connects to the worker nodes listed in the file of
+// DOCKER_NODES, or else to the Docker host configured by the environment.
+func NewEngineFromEnv() (Engine, error) {
+	path := os.Getenv("DOCKER_NODES")
+	if path == "" {
+		return NewDockerRuntimeFromEnv()
+	}
+	configs, err := LoadNodes(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewMultiRuntimeFromConfig(configs)
+}
+
+// available returns the nodes not backing off, the least loaded first.
+func (m *MultiRuntime) available() []*node {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	nodes := []*node{}
+	for _, n := range m.nodes {
+		if n.health.delay() == 0 {
+			nodes = append(nodes, n)
+		}
+	}
+	// load/capacity compared without division
+	slices.SortStableFunc(nodes, func(a, b *node) int {
+		return len(a.containers)*b.Capacity - len(b.containers)*a.Capacity
+	})
+	return nodes
+}
+
+// route returns the node of a prefixed id and the id on the node.
+func (m *MultiRuntime) route(id string) (*node, string, error) {
+	name, local, found := strings.Cut(id, "/")
+	if found {
+		for _, n := range m.nodes {
+			if n.Name == name {
+				return n, local, nil
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// nodeError tells apart the errors of a node that stopped answering, which is
+// then avoided until it answers again. The errors of a cancelled run are no
+// fault of the node.
+func (m *MultiRuntime) nodeError(ctx context.Context, n *node, err error) error {
+	if err == nil || errors.Is(err, ErrNotFound) || ctx.Err() != nil {
+		return err
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, NODE_PING_TIMEOUT)
+	defer cancel()
+	if pingErr := n.Runtime.Ping(pingCtx); pingErr != nil {
+		n.health.failed()
+		return fmt.Errorf("%w: %s: %v", ErrNodeDown, n.Name, err)
+	}
+	return err
+}
+
+// pinRef returns the tag pinning an image id on every node.
+func pinRef(id string) string {
+	return PIN_REPOSITORY + ":" + strings.TrimPrefix(id, "sha256:")
+}
+
+// Create creates the container on the least loaded node, trying the next ones
+// if it fails.
+func (m *MultiRuntime) Create(ctx context.Context, tutorial Tutorial, labels map[string]string) (string, error) {
+	if tutorial.Digest != "" {
+		tutorial.Digest = pinRef(tutorial.Digest)
+	}
+	nodes := m.available()
+	if len(nodes) == 0 {
+		return "", ErrNodeDown
+	}
+	var err error
+	for _, n := range nodes {
+		var id string
+		id, err = n.Runtime.Create(ctx, tutorial, labels)
+		if err == nil {
+			n.health.succeeded()
+			m.mu.Lock()
+			n.containers[id] = struct{}{}
+			m.mu.Unlock()
+			return n.Name + "/" + id, nil
+		}
+		err = m.nodeError(ctx, n, err)
+		log.Printf("Failed to create container on node %s: %v", n.Name, err)
+	}
+	return "", err
+}
+
+func (m *MultiRuntime) CopyFiles(ctx context.Context, id string, files []File) error {
+	n, local, err := m.route(id)
+	if err != nil {
+		return err
+	}
+	return m.nodeError(ctx, n, n.Runtime.CopyFiles(ctx, local, files))
+}
+
+func (m *MultiRuntime) Start(ctx context.Context, id string) error {
+	n, local, err := m.route(id)
+	if err != nil {
+		return err
+	}
+	return m.nodeError(ctx, n, n.Runtime.Start(ctx, local))
+}
+
+func (m *MultiRuntime) Wait(ctx context.Context, id string) (RunResponse, error) {
+	n, local, err := m.route(id)
+	if err != nil {
+		return RunResponse{}, err
+	}
+	status, err := n.Runtime.Wait(ctx, local)
+	return status, m.nodeError(ctx, n, err)
+}
+
+func (m *MultiRuntime) Kill(ctx context.Context, id string) error {
+	n, local, err := m.route(id)
+	if err != nil {
+		return err
+	}
+	return m.nodeError(ctx, n, n.Runtime.Kill(ctx, local))
+}
+
+func (m *MultiRuntime) Logs(ctx context.Context, id string, opts LogsOptions) (io.ReadCloser, error) {
+	n, local, err := m.route(id)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := n.Runtime.Logs(ctx, local, opts)
+	return logs, m.nodeError(ctx, n, err)
+}
+
+// Remove removes the container from its node, even one backing off.
+func (m *MultiRuntime) Remove(ctx context.Context, id string) {
+	n, local, err := m.route(id)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	n.Runtime.Remove(ctx, local)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(n.containers, local)
+}
+
+func (m *MultiRuntime) Inspect(ctx context.Context, id string) (ContainerState, error) {
+	n, local, err := m.route(id)
+	if err != nil {
+		return ContainerState{}, err
+	}
+	state, err := n.Runtime.Inspect(ctx, local)
+	return state, m.nodeError(ctx, n, err)
+}
+
+// List returns the containers of every node answering.
+func (m *MultiRuntime) List(ctx context.Context, labels map[string]string) ([]ContainerInfo, error) {
+	infos := []ContainerInfo{}
+	var lastErr error
+	for _, n := range m.nodes {
+		ctns, err := n.Runtime.List(ctx, labels)
+		if err != nil {
+			lastErr = m.nodeError(ctx, n, err)
+			log.Printf("Failed to list the containers of node %s: %v", n.Name, lastErr)
+			continue
+		}
+		for _, ctn := range ctns {
+			infos = append(infos, ContainerInfo{ID: n.Name + "/" + ctn.ID, Labels: ctn.Labels})
+		}
+	}
+	if len(infos) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return infos, nil
+}
+
+// Exec returns an execution id prefixed with the node, like the containers.
+func (m *MultiRuntime) Exec(ctx context.Context, id string, opts ExecOptions) (string, io.ReadCloser, error) {
+	n, local, err := m.route(id)
+	if err != nil {
+		return "", nil, err
+	}
+	execID, output, err := n.Runtime.Exec(ctx, local, opts)
+	if err != nil {
+		return "", nil, m.nodeError(ctx, n, err)
+	}
+	return n.Name + "/" + execID, output, nil
+}
+
+func (m *MultiRuntime) ExecWait(ctx context.Context, execID string) (RunResponse, error) {
+	n, local, err := m.route(execID)
+	if err != nil {
+		return RunResponse{}, err
+	}
+	status, err := n.Runtime.ExecWait(ctx, local)
+	return status, m.nodeError(ctx, n, err)
+}
+
+func (m *MultiRuntime) CleanRun(ctx context.Context, id string, dir string) error {
+	n, local, err := m.route(id)
+	if err != nil {
+		return err
+	}
+	return m.nodeError(ctx, n, n.Runtime.CleanRun(ctx, local, dir))
+}
+
+// Heartbeat records the heartbeat on every node answering, the reapers of the
+// other instances may only list some of them.
+func (m *MultiRuntime) Heartbeat(ctx context.Context, instance string, at time.Time) error {
+	var errs []error
+	for _, n := range m.available() {
+		if err := n.Runtime.Heartbeat(ctx, instance, at); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", n.Name, m.nodeError(ctx, n, err)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Instances merges the last heartbeats of the nodes answering. Errors when
+// none answers, no instance being known alive then.
+func (m *MultiRuntime) Instances(ctx context.Context) (map[string]time.Time, error) {
+	instances := map[string]time.Time{}
+	answered := false
+	var lastErr error
+	for _, n := range m.nodes {
+		heartbeats, err := n.Runtime.Instances(ctx)
+		if err != nil {
+			lastErr = m.nodeError(ctx, n, err)
+			continue
+		}
+		answered = true
+		for instance, at := range heartbeats {
+			if at.After(instances[instance]) {
+				instances[instance] = at
+			}
+		}
+	}
+	if !answered && lastErr != nil {
+		return nil, lastErr
+	}
+	return instances, nil
+}
+
+func (m *MultiRuntime) ForgetInstance(ctx context.Context, instance string) error {
+	var errs []error
+	for _, n := range m.available() {
+		if err := n.Runtime.ForgetInstance(ctx, instance); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", n.Name, m.nodeError(ctx, n, err)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// BuildImage builds the image on every node answering.
+func (m *MultiRuntime) BuildImage(ctx context.Context, files []File, tags []string) error {
+	nodes := m.available()
+	if len(nodes) == 0 {
+		return ErrNodeDown
+	}
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := n.Runtime.BuildImage(ctx, files, tags); err != nil {
+				errs[i] = fmt.Errorf("node %s: %w", n.Name, m.nodeError(ctx, n, err))
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// ImageID returns the id of the image on the first node answering, and pins
+// the image of each node to it. Errors if a node misses the image.
+func (m *MultiRuntime) ImageID(ctx context.Context, ref string) (string, error) {
+	pin := ""
+	// in the order of the config, so the pin is stable
+	for _, n := range m.nodes {
+		if n.health.delay() > 0 {
+			continue
+		}
+		id, err := n.Runtime.ImageID(ctx, ref)
+		if err != nil {
+			return "", fmt.Errorf("node %s: %w", n.Name, m.nodeError(ctx, n, err))
+		}
+		if pin == "" {
+			pin = id
+		}
+		if err := n.Runtime.TagImage(ctx, id, pinRef(pin)); err != nil {
+			return "", fmt.Errorf("node %s: %w", n.Name, m.nodeError(ctx, n, err))
+		}
+	}
+	if pin == "" {
+		return "", ErrNodeDown
+	}
+	return pin, nil
+}
+
+// CheckNodes pings every node, so a node backing off is used again once it answers.
+func (m *MultiRuntime) CheckNodes(ctx context.Context) {
+	for _, n := range m.nodes {
+		pingCtx, cancel := context.WithTimeout(ctx, NODE_PING_TIMEOUT)
+		err := n.Runtime.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			log.Printf("Node %s does not answer: %v", n.Name, err)
+			n.health.failed()
+			continue
+		}
+		n.health.succeeded()
+	}
+}
+
+// RunNodeChecks checks the nodes every interval until the context is done.
+func (m *MultiRuntime) RunNodeChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.CheckNodes(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package container_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nexzap/internal/services/container"
+)
+
+// newNodes returns a runtime spreading the containers on fake nodes a and b.
+func newNodes(capacityA, capacityB int) (*container.MultiRuntime, *container.FakeRuntime, *container.FakeRuntime) {
+	a := container.NewFakeRuntime(echoHandler)
+	b := container.NewFakeRuntime(echoHandler)
+	rt := container.NewMultiRuntime([]container.Node{
+		{Name: "a", Runtime: a, Capacity: capacityA},
+		{Name: "b", Runtime: b, Capacity: capacityB},
+	})
+	return rt, a, b
+}
+
+func TestMultiRuntime_SpreadsByLoad(t *testing.T) {
+	ctx := context.Background()
+	rt, a, b := newNodes(1, 2)
+	tutorial := container.Tutorial{Image: "gotest", Command: []string{"go", "test"}}
+
+	ctns := []string{}
+	for range 6 {
+		ctn, err := rt.Create(ctx, tutorial, nil)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		ctns = append(ctns, ctn)
+	}
+	if len(a.Containers()) != 2 || len(b.Containers()) != 4 {
+		t.Errorf("expected 2 containers on a and 4 on b, got %d and %d", len(a.Containers()), len(b.Containers()))
+	}
+
+	// The calls reach the node of the container
+	output, status, err := container.Run(ctx, rt, ctns[5], nil, container.DEFAULT_OUTPUT_LIMIT)
+	if err != nil || status.StatusCode != 0 || output.Stdout != "ok go test" {
+		t.Errorf("expected the run to succeed, got %q, %+v, %v", output.Stdout, status, err)
+	}
+	for _, ctn := range ctns {
+		rt.Remove(ctx, ctn)
+	}
+	if len(a.Containers()) != 0 || len(b.Containers()) != 0 {
+		t.Errorf("expected the containers removed, got %v and %v", a.Containers(), b.Containers())
+	}
+}
+
+func TestMultiRuntime_RemoveUntracked(t *testing.T) {
+	ctx := context.Background()
+	rt, a, b := newNodes(1, 1)
+	tutorial := container.Tutorial{Image: "gotest"}
+	for range 3 {
+		if _, err := rt.Create(ctx, tutorial, nil); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	// An orphan left by another instance, not counted in the load of a
+	rt.Remove(ctx, "a/orphan")
+	if _, err := rt.Create(ctx, tutorial, nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if len(a.Containers()) != 2 || len(b.Containers()) != 2 {
+		t.Errorf("expected 2 containers on each node, got %d and %d", len(a.Containers()), len(b.Containers()))
+	}
+}
+
+func TestMultiRuntime_NodeDown(t *testing.T) {
+	ctx := context.Background()
+	rt, a, b := newNodes(1, 1)
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		return container.PoolPolicy{Min: 2, Max: 2}
+	})
+	defer pool.CleanAll(ctx, rt)
+	imagePool := pool.GetImagePool(ctx, rt, container.Tutorial{Image: "gotest"})
+	if len(a.Containers()) != 1 || len(b.Containers()) != 1 {
+		t.Fatalf("expected a container on each node, got %v and %v", a.Containers(), b.Containers())
+	}
+
+	// The container on a is replaced by one on b without failing any run
+	a.SetDown(true)
+	for i := range 2 * container.HEALTH_FAILURES {
+		ctn, err := imagePool.GetContainer(ctx, rt, container.Waiter{})
+		if err != nil {
+			t.Fatalf("run %d: GetContainer failed: %v", i, err)
+		}
+		if !strings.HasPrefix(ctn, "b/") {
+			t.Errorf("run %d: expected a container of b, got %s", i, ctn)
+		}
+		if _, _, err := container.Run(ctx, rt, ctn, nil, container.DEFAULT_OUTPUT_LIMIT); err != nil {
+			t.Fatalf("run %d: Run failed: %v", i, err)
+		}
+		imagePool.FreeContainer(ctx, rt, ctn)
+	}
+
+	// Back once it answers
+	a.SetDown(false)
+	rt.CheckNodes(ctx)
+	if _, err := rt.Create(ctx, container.Tutorial{Image: "gotest"}, nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	waitFor(t, "a container on a", func() bool { return len(a.Containers()) > 0 })
+}
+
+func TestMultiRuntime_PinsImages(t *testing.T) {
+	ctx := context.Background()
+	rt, a, b := newNodes(1, 1)
+	a.AddImage("gotest")
+	// the same image gets another id on b
+	b.AddImage("other")
+	b.AddImage("gotest")
+
+	pin, err := rt.ImageID(ctx, "gotest")
+	if err != nil {
+		t.Fatalf("ImageID failed: %v", err)
+	}
+	if id, _ := a.ImageID(ctx, "gotest"); pin != id {
+		t.Errorf("expected the id of the first node %s, got %s", id, pin)
+	}
+	tutorial := container.Tutorial{Image: "gotest", Digest: pin}
+	for range 2 {
+		if _, err := rt.Create(ctx, tutorial, nil); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	if len(a.Containers()) != 1 || len(b.Containers()) != 1 {
+		t.Errorf("expected the pinned image on both nodes, got %v and %v", a.Containers(), b.Containers())
+	}
+}
+
+func TestLoadNodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		nodes   []container.NodeConfig
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			config: `
+[[node]]
+name = "local"
+host = "unix:///var/run/docker.sock"
+
+[[node]]
+name = "worker1"
+host = "tcp://10.0.0.2:2376"
+tls = "/etc/nexzap/certs/worker1"
+capacity = 2
+`,
+			nodes: []container.NodeConfig{
+				{Name: "local", Host: "unix:///var/run/docker.sock", Capacity: 1},
+				{Name: "worker1", Host: "tcp://10.0.0.2:2376", TLS: "/etc/nexzap/certs/worker1", Capacity: 2},
+			},
+		},
+		{name: "empty", config: "", wantErr: true},
+		{name: "duplicate", config: "[[node]]\nname = \"a\"\nhost = \"h\"\n[[node]]\nname = \"a\"\nhost = \"h\"\n", wantErr: true},
+		{name: "no host", config: "[[node]]\nname = \"a\"\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "nodes.toml")
+			if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			nodes, err := container.LoadNodes(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if len(nodes) != len(tt.nodes) {
+				t.Fatalf("expected %d nodes, got %d", len(tt.nodes), len(nodes))
+			}
+			for i := range nodes {
+				if nodes[i] != tt.nodes[i] {
+					t.Errorf("expected %+v, got %+v", tt.nodes[i], nodes[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package container
+
+import (
+	"io"
+	"strings"
+)
+
+// Streams of the output of a container.
+const (
+	STDOUT = "stdout"
+	STDERR = "stderr"
+)
+
+// Chunk is a part of the output written at once on one of the streams.
+type Chunk struct {
+	Stream string `json:"stream"` // STDOUT or STDERR
+	Text   string `json:"text"`
+}
+
+// Output is the output of a run, with stdout and stderr apart. Chunks keeps
+// the order in which they were written.
+type Output struct {
+	Stdout string
+	Stderr string
+	Chunks []Chunk
+}
+
+// NewOutput returns the output of a run writing stdout, then stderr.
+func NewOutput(stdout, stderr string) Output {
+	output := Output{Stdout: stdout, Stderr: stderr, Chunks: []Chunk{}}
+	if stdout != "" {
+		output.Chunks = append(output.Chunks, Chunk{Stream: STDOUT, Text: stdout})
+	}
+	if stderr != "" {
+		output.Chunks = append(output.Chunks, Chunk{Stream: STDERR, Text: stderr})
+	}
+	return output
+}
+
+// Combined returns stdout and stderr interleaved as they were written.
+func (o Output) Combined() string {
+	var b strings.Builder
+	for _, chunk := range o.Chunks {
+		b.WriteString(chunk.Text)
+	}
+	return b.String()
+}
+
+// Map returns the output with the text of every chunk and stream transformed.
+func (o Output) Map(f func(string) string) Output {
+	mapped := Output{Stdout: f(o.Stdout), Stderr: f(o.Stderr), Chunks: make([]Chunk, 0, len(o.Chunks))}
+	for _, chunk := range o.Chunks {
+		mapped.Chunks = append(mapped.Chunks, Chunk{Stream: chunk.Stream, Text: f(chunk.Text)})
+	}
+	return mapped
+}
+
+// OutputRecorder builds an Output from the writes on its two streams.
+type OutputRecorder struct {
+	stdout strings.Builder
+	stderr strings.Builder
+	chunks []Chunk
+}
+
+// Writer returns the writer of a stream, STDOUT or STDERR.
+func (r *OutputRecorder) Writer(stream string) io.Writer {
+	return &streamWriter{recorder: r, stream: stream}
+}
+
+// Output returns what was written so far.
+func (r *OutputRecorder) Output() Output {
+	return Output{Stdout: r.stdout.String(), Stderr: r.stderr.String(), Chunks: r.chunks}
+}
+
+type streamWriter struct {
+	recorder *OutputRecorder
+	stream   string
+}
+
+// Write appends to the last chunk when it is of the same stream.
+func (w *streamWriter) Write(p []byte) (int, error) {
+	r := w.recorder
+	if w.stream == STDERR {
+		r.stderr.Write(p)
+	} else {
+		r.stdout.Write(p)
+	}
+	if last := len(r.chunks) - 1; last >= 0 && r.chunks[last].Stream == w.stream {
+		r.chunks[last].Text += string(p)
+	} else {
+		r.chunks = append(r.chunks, Chunk{Stream: w.stream, Text: string(p)})
+	}
+	return len(p), nil
+}
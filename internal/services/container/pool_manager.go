@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
-
-	"github.com/docker/docker/client"
 )
 
+// Defaults of the pool policy, see PoolPolicy
 const (
 	// number of always-up container
 	MIN_CTN = 3
@@ -19,85 +19,164 @@ const (
 	LANGUAGE_TIMEOUT = 3 * time.Minute
 	// time before a extended container is discared
 	CONTAINER_TIMEOUT = 15 * time.Second
-	// time before we signal there's no container available
+	// time a submission waits in the queue before we signal there's no container available
 	WAIT_TIMEOUT = 10 * time.Second
 )
 
 type Tutorial struct {
-	Image   string
+	Image string
+	// Digest pins the content of the image when set, Image is then only its name
+	Digest  string
 	Command []string
+	Limits  Limits
+	Sandbox Sandbox
+	Caches  []Cache
+	// Mode tells how the runs use the containers, ModeStart when empty
+	Mode RunMode
+	// OnDemand keeps no container ready, for the pools used now and then
+	OnDemand bool
+}
+
+// key identifies the pool of the tutorial. Containers are only shared between
+// tutorials created with the same image, command, limits, sandbox, caches and
+// mode. The timeout is left out, it only bounds each run.
+func (t Tutorial) key() string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%v|%s|%t", t.Image, t.Digest, strings.Join(t.Command, " "), t.Limits.created(), t.Sandbox, t.Caches, t.Mode, t.OnDemand)
+}
+
+// ref returns the reference of the image to create the containers from.
+func (t Tutorial) ref() string {
+	if t.Digest != "" {
+		return t.Digest
+	}
+	return t.Image
 }
 
 type Pool struct {
 	sync.Mutex
-	pool map[string]ImagePool
+	pool   map[string]*ImagePool
+	reset  ResetPolicy
+	policy PoolPolicyFunc
+	// failures of the containers by image, shared by its pools
+	health map[string]*backoff
 }
 
 func NewPool() Pool {
-	return Pool{pool: make(map[string]ImagePool)}
+	return Pool{
+		pool:   make(map[string]*ImagePool),
+		reset:  ResetRecreate,
+		health: make(map[string]*backoff),
+	}
+}
+
+// SetResetPolicy changes how containers are cleaned between runs.
+// Only applies to the image pools created afterwards.
+func (p *Pool) SetResetPolicy(policy ResetPolicy) {
+	p.Lock()
+	defer p.Unlock()
+	p.reset = policy
+}
+
+// SetPoolPolicy sets how the pool of each image is sized.
+// Only applies to the image pools created afterwards.
+func (p *Pool) SetPoolPolicy(policy PoolPolicyFunc) {
+	p.Lock()
+	defer p.Unlock()
+	p.policy = policy
+}
+
+// poolPolicy returns the policy of an image, the default one when none is set.
+func (p *Pool) poolPolicy(image string) PoolPolicy {
+	if p.policy == nil {
+		return DefaultPoolPolicy()
+	}
+	policy := p.policy(image).WithDefaults()
+	// One container must always be possible for the image to be usable
+	policy.Max = max(policy.Max, 1)
+	return policy
 }
 
 // GetImagePool creates a pool for a given language if it doesn't exist, then returns it.
 // Synchronized method to avoid duplicate language pool.
-func (p *Pool) GetImagePool(ctx context.Context, cli *client.Client, tutorial Tutorial) ImagePool {
+func (p *Pool) GetImagePool(ctx context.Context, rt Runtime, tutorial Tutorial) *ImagePool {
 	p.Lock()
 	defer p.Unlock()
-	if lp, ok := p.pool[tutorial.Image]; ok {
+	if lp, ok := p.pool[tutorial.key()]; ok {
 		return lp
 	}
-	p.newImage(ctx, cli, tutorial)
-	return p.pool[tutorial.Image]
+	p.newImage(ctx, rt, tutorial)
+	return p.pool[tutorial.key()]
 }
 
 // newImage adds a pool for a language in the main pool.
 // Defines its configuration and starts its base container.
 func (p *Pool) newImage(
 	ctx context.Context,
-	cli *client.Client,
+	rt Runtime,
 	lang Tutorial,
 ) {
-	// Create the language
-	language := ImagePool{
-		MinPool:         make(chan string, MIN_CTN),
-		ExtendedPool:    make(chan string, MAX_CTN),
-		extensionSlots:  make(chan any, MAX_CTN),
-		language:        lang,
-		languageTimeout: *NewTimeout(LANGUAGE_TIMEOUT, nil),
-		extendTimeout:   *NewTimeout(CONTAINER_TIMEOUT, nil),
+	policy := p.poolPolicy(lang.Image)
+	if lang.OnDemand {
+		policy.Min = 0
 	}
-
-	language.languageTimeout.action = func() {
-		p.cleanImage(ctx, cli, lang.Image)
+	if p.health[lang.Image] == nil {
+		p.health[lang.Image] = &backoff{}
 	}
 
-	language.extendTimeout.action = func() {
-		for c := range language.ExtendedPool {
-			StopAndRemove(ctx, cli, c)
-			<-language.extensionSlots
-		}
+	// Create the language
+	language := &ImagePool{
+		MinPool:        make(chan string, policy.Min),
+		ExtendedPool:   make(chan string, policy.Max),
+		extensionSlots: make(chan any, policy.Max),
+		language:       lang,
+		policy:         policy,
+		reset:          p.reset,
+		queue:          newQueue(),
+		health:         p.health[lang.Image],
 	}
+	language.languageTimeout = NewTimeout(policy.LanguageTimeout, func() {
+		poolTimeouts.WithLabelValues(lang.Image, "language").Inc()
+		p.cleanImage(ctx, rt, language)
+	})
+	language.extendTimeout = NewTimeout(policy.ContainerTimeout, func() {
+		poolTimeouts.WithLabelValues(lang.Image, "extended").Inc()
+		language.shrink(ctx, rt)
+	})
 
-	// Create the minPool
+	// Create the minPool, a failed container is retried in the background
 	var wg sync.WaitGroup
-	wg.Add(MIN_CTN)
-	for range MIN_CTN {
+	wg.Add(policy.Min)
+	for range policy.Min {
 		go func() {
 			defer wg.Done()
-			resp, err := createContainer(ctx, cli, lang)
+			id, err := createContainer(ctx, rt, lang, PoolMin)
 			if err != nil {
-				panic(err)
+				log.Printf("Failed to create container of %s: %v", lang.Image, err)
+				language.health.failed()
+				go language.recreate(ctx, rt)
+				return
 			}
-			language.MinPool <- resp.ID
+			language.MinPool <- id
 		}()
 	}
 	wg.Wait()
 
-	p.pool[lang.Image] = language
+	p.pool[lang.key()] = language
 }
 
 // ImagePool represents a pool of containers for a specific language.
 type ImagePool struct {
 	language Tutorial
+	policy   PoolPolicy
+	reset    ResetPolicy
+	health   *backoff
+	// protects the channels from being used once closed by cleanImage
+	mu     sync.Mutex
+	closed bool
+	// containers not recreated since the image broke, see recreate
+	missing int
+	// submissions waiting for a container
+	queue queue
 	// pool of containers that should always be running
 	MinPool chan string
 	// pool of containers that can shrink or expand
@@ -105,58 +184,152 @@ type ImagePool struct {
 	// Quantity of containers still possible to deploy.
 	// Needed to take place before instanciating the container in ExtendedPool
 	extensionSlots  chan any
-	languageTimeout Timeout
-	extendTimeout   Timeout
+	languageTimeout *Timeout
+	extendTimeout   *Timeout
 }
 
 // GetContainer queries a container from the language pool and resets the timeout.
 // The language pool can create new containers to keep a margin.
-// You must free it after usage.
-func (lp *ImagePool) GetContainer(ctx context.Context, cli *client.Client) (string, error) {
-	// Check if container in MinPool
-	select {
-	case c := <-lp.MinPool:
-		lp.languageTimeout.StartTimer()
-		return c, nil
-	default:
+// When none is free, the submission waits in the queue of the pool and is
+// notified of its position. A dead container is replaced and the next one is
+// taken, until the image is considered broken. You must free it after usage.
+func (lp *ImagePool) GetContainer(ctx context.Context, rt Runtime, w Waiter) (string, error) {
+	start := time.Now()
+	ctn, err := lp.getContainer(ctx, rt, w)
+	for err == nil && !lp.check(ctx, rt, ctn) {
+		lp.replace(ctx, rt, ctn)
+		if lp.health.broken() {
+			err = ErrImageUnhealthy
+			break
+		}
+		ctn, err = lp.getContainer(ctx, rt, w)
 	}
+	observeWait(lp.language.Image, start, err)
+	return ctn, err
+}
 
-	// Check if container in ExtendedPool
-	select {
-	case c := <-lp.ExtendedPool:
-		lp.languageTimeout.StartTimer()
-		lp.extendTimeout.StartTimer()
-		return c, nil
-	default:
+func (lp *ImagePool) getContainer(ctx context.Context, rt Runtime, w Waiter) (string, error) {
+	lp.mu.Lock()
+	if lp.closed {
+		lp.mu.Unlock()
+		return "", fmt.Errorf("pool of %s is closed", lp.language.Image)
+	}
+	// Only take a free container directly when nobody is waiting before us
+	if lp.queue.size == 0 {
+		// Check if container in MinPool
+		select {
+		case c := <-lp.MinPool:
+			lp.mu.Unlock()
+			return lp.take(grant{id: c}), nil
+		default:
+		}
+
+		// Check if container in ExtendedPool
+		select {
+		case c := <-lp.ExtendedPool:
+			lp.mu.Unlock()
+			return lp.take(grant{id: c, extended: true}), nil
+		default:
+		}
+	}
+	t, err := lp.queue.push(w.Client)
+	lp.mu.Unlock()
+	if err != nil {
+		return "", err
 	}
 
 	// NOTE : the container hitting the limit of available container will always
 	// have to wait for a new one. This slows down the system with burst of
 	// submission. However we can consider this as acceptable for simplicity
 
-	// Else has too wait and get first free container
-	extendContainer(ctx, cli, lp)
-	select {
-	case c := <-lp.MinPool:
-		lp.languageTimeout.StartTimer()
-		return c, nil
-	case c := <-lp.ExtendedPool:
-		lp.languageTimeout.StartTimer()
+	// Else has too wait for its turn in the queue
+	extendContainer(ctx, rt, lp)
+	timeout := time.After(lp.policy.WaitTimeout)
+	for {
+		select {
+		case g, ok := <-t.ready:
+			if !ok {
+				return "", fmt.Errorf("pool of %s is closed", lp.language.Image)
+			}
+			return lp.take(g), nil
+		case position := <-t.positions:
+			if w.OnQueue != nil {
+				w.OnQueue(position)
+			}
+		case <-timeout:
+			return lp.leave(t, nil)
+		case <-ctx.Done():
+			return lp.leave(t, ctx.Err())
+		}
+	}
+}
+
+// take resets the timeouts for a container handed out.
+func (lp *ImagePool) take(g grant) string {
+	lp.languageTimeout.StartTimer()
+	if g.extended {
 		lp.extendTimeout.StartTimer()
-		return c, nil
-	case <-time.After(WAIT_TIMEOUT):
-		return "", fmt.Errorf("timeout waiting for container")
 	}
+	return g.id
+}
 
+// leave withdraws a ticket from the queue. If the ticket has been served
+// meanwhile, its container is returned instead of the error. A nil error
+// means the wait timed out and reports the position in the queue.
+func (lp *ImagePool) leave(t *ticket, err error) (string, error) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	position := lp.queue.position(t)
+	if lp.queue.remove(t) {
+		if err == nil {
+			err = &BusyError{Position: position}
+		}
+		return "", err
+	}
+	g, ok := <-t.ready
+	if !ok {
+		return "", fmt.Errorf("pool of %s is closed", lp.language.Image)
+	}
+	return lp.take(g), nil
 }
 
 // FreeContainer returns a container to the pool after usage.
-// WARNING : it does not stop or remove the container
+// With ResetRecreate, the container is replaced in the background by a new one
+// so the next run gets a clean workspace. The containers of ModeExec clean
+// up after each run and are kept. A container removed by its run is always
+// replaced.
 func (lp *ImagePool) FreeContainer(
 	ctx context.Context,
-	cli *client.Client,
+	rt Runtime,
 	ctn string,
 ) {
+	if isOwned(ctn) && (lp.reset != ResetRecreate || lp.language.Mode == ModeExec) {
+		lp.release(ctx, rt, ctn)
+		return
+	}
+	lp.replace(ctx, rt, ctn)
+}
+
+// isClosed tells whether the pool has been cleaned.
+func (lp *ImagePool) isClosed() bool {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return lp.closed
+}
+
+// release puts a container in the pool, or removes it if the pool has been cleaned meanwhile.
+func (lp *ImagePool) release(ctx context.Context, rt Runtime, ctn string) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	if lp.closed {
+		removeContainer(ctx, rt, ctn)
+		return
+	}
+	if t := lp.queue.pop(); t != nil {
+		t.ready <- grant{id: ctn}
+		return
+	}
+
 	// First try to give it to MinPool
 	select {
 	case lp.MinPool <- ctn:
@@ -169,50 +342,103 @@ func (lp *ImagePool) FreeContainer(
 	case lp.MinPool <- ctn:
 	case lp.ExtendedPool <- ctn:
 	default:
-		log.Fatalf("Container that shouldn't have been up")
+		// More containers than the pool accounts for, a bug, not a reason to stop serving
+		log.Printf("Container %s of %s has no room in the pool, removing it", ctn, lp.language.Image)
+		removeContainer(ctx, rt, ctn)
+	}
+}
+
+// shrink removes the free containers of the extended pool and their slots.
+func (lp *ImagePool) shrink(ctx context.Context, rt Runtime) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	if lp.closed {
+		return
+	}
+	for {
+		select {
+		case c := <-lp.ExtendedPool:
+			removeContainer(ctx, rt, c)
+			select {
+			case <-lp.extensionSlots:
+			default:
+			}
+		default:
+			return
+		}
 	}
 }
 
 // extendContainer extends the container pool if there's still slot available
-func extendContainer(ctx context.Context, cli *client.Client, lp *ImagePool) {
+func extendContainer(ctx context.Context, rt Runtime, lp *ImagePool) {
 	select {
 	case lp.extensionSlots <- struct{}{}:
-		go createAndAddContainer(ctx, cli, lp)
+		go createAndAddContainer(ctx, rt, lp)
 	default:
 	}
 }
 
 // createAndAddContainer creates a new container and adds it to the extended pool.
-func createAndAddContainer(ctx context.Context, cli *client.Client, lp *ImagePool) {
-	resp, err := createContainer(ctx, cli, lp.language)
+func createAndAddContainer(ctx context.Context, rt Runtime, lp *ImagePool) {
+	time.Sleep(lp.health.delay())
+	id, err := createContainer(ctx, rt, lp.language, PoolExtended)
 	if err != nil {
+		log.Printf("Failed to create container of %s: %v", lp.language.Image, err)
+		lp.health.failed()
 		<-lp.extensionSlots
-	} else {
-		lp.ExtendedPool <- resp.ID
+		return
 	}
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	if lp.closed {
+		removeContainer(ctx, rt, id)
+		return
+	}
+	if t := lp.queue.pop(); t != nil {
+		t.ready <- grant{id: id, extended: true}
+		return
+	}
+	lp.ExtendedPool <- id
 }
 
-// cleanImage removes a language from the main pool and cleans the minPool.
-// extendedPool will automatically be cleaned with a proper timeout.
-func (p *Pool) cleanImage(ctx context.Context, cli *client.Client, name string) {
+// cleanImage removes a language from the main pool, unless it has already
+// been replaced, and removes its free containers. Containers in use are
+// removed when freed.
+func (p *Pool) cleanImage(ctx context.Context, rt Runtime, language *ImagePool) {
 	p.Lock()
 	defer p.Unlock()
-	language, ok := p.pool[name]
-	if !ok {
+	name := language.language.key()
+	if p.pool[name] != language {
 		return
 	}
+	language.languageTimeout.Stop()
+	language.extendTimeout.Stop()
+	language.mu.Lock()
+	defer language.mu.Unlock()
+	language.closed = true
+	for t := language.queue.pop(); t != nil; t = language.queue.pop() {
+		close(t.ready)
+	}
 	close(language.MinPool)
 	close(language.ExtendedPool)
 	for ctn := range language.MinPool {
-		StopAndRemove(ctx, cli, ctn)
+		removeContainer(ctx, rt, ctn)
+	}
+	for ctn := range language.ExtendedPool {
+		removeContainer(ctx, rt, ctn)
 	}
 	delete(p.pool, name)
 }
 
-// CleanAll is not concurrent safe !
-func (p *Pool) CleanAll(ctx context.Context, cli *client.Client) {
-	for imageName := range p.pool {
-		p.cleanImage(ctx, cli, imageName)
-		delete(p.pool, imageName)
+// CleanAll removes the pool of every image and their free containers.
+func (p *Pool) CleanAll(ctx context.Context, rt Runtime) {
+	p.Lock()
+	languages := make([]*ImagePool, 0, len(p.pool))
+	for _, language := range p.pool {
+		languages = append(languages, language)
+	}
+	p.Unlock()
+	for _, language := range languages {
+		p.cleanImage(ctx, rt, language)
 	}
 }
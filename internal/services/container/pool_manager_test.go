@@ -0,0 +1,291 @@
+package container_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nexzap/internal/metrics"
+	"nexzap/internal/services/container"
+)
+
+// waitFor polls the condition until it holds or fails the test after a second.
+func waitFor(t *testing.T, msg string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", msg)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// scrape returns the metrics as served on the metrics endpoint.
+func scrape() string {
+	rec := httptest.NewRecorder()
+	metrics.Handler("").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestPool_GetAndFreeContainer(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	pool := container.NewPool()
+	tutorial := container.Tutorial{Image: "gotest", Command: []string{"go", "test"}}
+
+	imagePool := pool.GetImagePool(ctx, rt, tutorial)
+	if got := len(rt.Containers()); got != container.MIN_CTN {
+		t.Fatalf("expected %d containers after init, got %d", container.MIN_CTN, got)
+	}
+
+	ctns := []string{}
+	for range container.MIN_CTN {
+		ctn, err := imagePool.GetContainer(ctx, rt, container.Waiter{})
+		if err != nil {
+			t.Fatalf("GetContainer failed: %v", err)
+		}
+		ctns = append(ctns, ctn)
+	}
+	for _, ctn := range ctns {
+		imagePool.FreeContainer(ctx, rt, ctn)
+	}
+	waitFor(t, "containers to be recreated", func() bool {
+		return len(imagePool.MinPool) == container.MIN_CTN
+	})
+	for _, ctn := range ctns {
+		for _, alive := range rt.Containers() {
+			if ctn == alive {
+				t.Errorf("used container %s should have been replaced", ctn)
+			}
+		}
+	}
+
+	pool.CleanAll(ctx, rt)
+	if got := len(rt.Containers()); got != 0 {
+		t.Errorf("expected all containers removed, got %d", got)
+	}
+}
+
+func TestPool_SharedAcrossTimeouts(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	pool := container.NewPool()
+	defer pool.CleanAll(ctx, rt)
+	short := container.Tutorial{Image: "gotest", Limits: container.Limits{Timeout: 5 * time.Second}}
+	long := container.Tutorial{Image: "gotest", Limits: container.Limits{Timeout: time.Minute}}
+	bigger := container.Tutorial{Image: "gotest", Limits: container.Limits{Memory: 1 << 30}}
+
+	if pool.GetImagePool(ctx, rt, short) != pool.GetImagePool(ctx, rt, long) {
+		t.Errorf("expected the sheets differing by their timeout to share a pool")
+	}
+	if pool.GetImagePool(ctx, rt, short) == pool.GetImagePool(ctx, rt, bigger) {
+		t.Errorf("expected the sheets differing by their memory to have their own pool")
+	}
+	if got := len(rt.Containers()); got != 2*container.MIN_CTN {
+		t.Errorf("expected %d containers, got %d", 2*container.MIN_CTN, got)
+	}
+}
+
+func TestPool_ResetIsolatesRuns(t *testing.T) {
+	tests := []struct {
+		policy container.ResetPolicy
+		leak   bool
+	}{
+		{container.ResetRecreate, false},
+		{container.ResetNone, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			ctx := context.Background()
+			rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+				if _, ok := files["secret.txt"]; ok {
+					return container.FakeResult{Stdout: "leaked", StatusCode: 1}
+				}
+				return container.FakeResult{Written: map[string]string{"secret.txt": "learner data"}}
+			})
+			pool := container.NewPool()
+			pool.SetResetPolicy(tt.policy)
+			defer pool.CleanAll(ctx, rt)
+			imagePool := pool.GetImagePool(ctx, rt, container.Tutorial{Image: "gotest"})
+
+			leaked := false
+			for range 2 * container.MIN_CTN {
+				ctn, err := imagePool.GetContainer(ctx, rt, container.Waiter{})
+				if err != nil {
+					t.Fatalf("GetContainer failed: %v", err)
+				}
+				_, status, err := container.Run(ctx, rt, ctn, nil, container.DEFAULT_OUTPUT_LIMIT)
+				if err != nil {
+					t.Fatalf("Run failed: %v", err)
+				}
+				leaked = leaked || status.StatusCode != 0
+				imagePool.FreeContainer(ctx, rt, ctn)
+			}
+			if leaked != tt.leak {
+				t.Errorf("expected leak %v, got %v", tt.leak, leaked)
+			}
+		})
+	}
+}
+
+func TestPool_PoolPolicy(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		if image == "archived" {
+			return container.PoolPolicy{Min: 0, Max: 1, WaitTimeout: 50 * time.Millisecond}
+		}
+		return container.PoolPolicy{Min: -1}
+	})
+	defer pool.CleanAll(ctx, rt)
+
+	pool.GetImagePool(ctx, rt, container.Tutorial{Image: "current"})
+	if got := len(rt.Containers()); got != container.MIN_CTN {
+		t.Fatalf("expected %d containers for the default policy, got %d", container.MIN_CTN, got)
+	}
+
+	imagePool := pool.GetImagePool(ctx, rt, container.Tutorial{Image: "archived"})
+	if got := len(rt.Containers()); got != container.MIN_CTN {
+		t.Fatalf("expected no warm container for the archived image, got %d", got-container.MIN_CTN)
+	}
+	ctn, err := imagePool.GetContainer(ctx, rt, container.Waiter{})
+	if err != nil {
+		t.Fatalf("GetContainer failed: %v", err)
+	}
+	// The only container allowed is in use
+	_, err = imagePool.GetContainer(ctx, rt, container.Waiter{})
+	var busy *container.BusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("expected busy error, got %v", err)
+	}
+	imagePool.FreeContainer(ctx, rt, ctn)
+}
+
+// TestPool_ConcurrentTimeouts interleaves the runs with the idle timeouts
+// discarding the pool and its extended containers, run it with -race.
+func TestPool_ConcurrentTimeouts(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		return container.PoolPolicy{
+			Min:              1,
+			Max:              2,
+			LanguageTimeout:  3 * time.Millisecond,
+			ContainerTimeout: time.Millisecond,
+			WaitTimeout:      20 * time.Millisecond,
+		}
+	})
+	tutorial := container.Tutorial{Image: "gotest"}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				imagePool := pool.GetImagePool(ctx, rt, tutorial)
+				// The pool may be closed or busy meanwhile
+				ctn, err := imagePool.GetContainer(ctx, rt, container.Waiter{})
+				if err != nil {
+					continue
+				}
+				time.Sleep(time.Millisecond)
+				imagePool.FreeContainer(ctx, rt, ctn)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 10 {
+			pool.CleanAll(ctx, rt)
+			time.Sleep(2 * time.Millisecond)
+		}
+	}()
+	wg.Wait()
+
+	pool.CleanAll(ctx, rt)
+	waitFor(t, "all the containers to be removed", func() bool {
+		return len(rt.Containers()) == 0
+	})
+}
+
+func TestPool_CleanStopsLanguageTimeout(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		return container.PoolPolicy{Min: 0, Max: 1, LanguageTimeout: 20 * time.Millisecond}
+	})
+	defer pool.CleanAll(ctx, rt)
+	tutorial := container.Tutorial{Image: "gotest"}
+
+	imagePool := pool.GetImagePool(ctx, rt, tutorial)
+	ctn, err := imagePool.GetContainer(ctx, rt, container.Waiter{})
+	if err != nil {
+		t.Fatalf("GetContainer failed: %v", err)
+	}
+	imagePool.FreeContainer(ctx, rt, ctn)
+	pool.CleanAll(ctx, rt)
+
+	// The timeout of the cleaned pool must not discard the new one
+	recreated := pool.GetImagePool(ctx, rt, tutorial)
+	time.Sleep(50 * time.Millisecond)
+	if pool.GetImagePool(ctx, rt, tutorial) != recreated {
+		t.Error("expected the new pool to survive the timeout of the cleaned one")
+	}
+}
+
+func TestPool_ReleaseWithoutRoom(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		return container.PoolPolicy{Min: 1, Max: 0}
+	})
+	defer pool.CleanAll(ctx, rt)
+	imagePool := pool.GetImagePool(ctx, rt, container.Tutorial{Image: "gotest", Mode: container.ModeExec})
+
+	ctn, err := imagePool.GetContainer(ctx, rt, container.Waiter{})
+	if err != nil {
+		t.Fatalf("GetContainer failed: %v", err)
+	}
+	// Freed twice, the second one has no room left and must not stop the server
+	imagePool.FreeContainer(ctx, rt, ctn)
+	imagePool.FreeContainer(ctx, rt, ctn)
+	if len(imagePool.MinPool) != 1 {
+		t.Errorf("expected the pool to keep its container, got %d", len(imagePool.MinPool))
+	}
+}
+
+func TestPool_ExportMetrics(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	pool := container.NewPool()
+	pool.SetPoolPolicy(func(image string) container.PoolPolicy {
+		return container.PoolPolicy{Min: 2, Max: 1}
+	})
+	defer pool.CleanAll(ctx, rt)
+	pool.ExportMetrics()
+	pool.GetImagePool(ctx, rt, container.Tutorial{Image: "metricstest"})
+
+	scraped := scrape()
+	for _, line := range []string{
+		`nexzap_pool_free_containers{image="metricstest",pool="min"} 2`,
+		`nexzap_pool_free_containers{image="metricstest",pool="extended"} 0`,
+		`nexzap_pool_queued_submissions{image="metricstest"} 0`,
+	} {
+		if !strings.Contains(scraped, line+"\n") {
+			t.Errorf("expected %q in the metrics", line)
+		}
+	}
+}
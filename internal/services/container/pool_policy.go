@@ -0,0 +1,61 @@
+package container
+
+import (
+	"fmt"
+	"time"
+)
+
+// PoolPolicy sizes the pool of an image and defines how long its containers stay idle.
+// Zero values mean the default, except Min where zero disables the warm pool.
+type PoolPolicy struct {
+	// number of always-up containers, negative means MIN_CTN
+	Min int
+	// number of maximum additional containers
+	Max int
+	// time before the image is fully discarded and thus its base containers
+	LanguageTimeout time.Duration
+	// time before an extended container is discarded
+	ContainerTimeout time.Duration
+	// time a submission waits in the queue before we signal there's no container available
+	WaitTimeout time.Duration
+}
+
+// PoolPolicyFunc returns the policy of an image. It is called each time the
+// pool of an image is created, so the policy can change over time.
+type PoolPolicyFunc func(image string) PoolPolicy
+
+// DefaultPoolPolicy returns the policy used when none is set for an image.
+func DefaultPoolPolicy() PoolPolicy {
+	return PoolPolicy{
+		Min:              MIN_CTN,
+		Max:              MAX_CTN,
+		LanguageTimeout:  LANGUAGE_TIMEOUT,
+		ContainerTimeout: CONTAINER_TIMEOUT,
+		WaitTimeout:      WAIT_TIMEOUT,
+	}
+}
+
+// WithDefaults returns the policy with the unset values replaced by the defaults.
+func (p PoolPolicy) WithDefaults() PoolPolicy {
+	if p.Min < 0 {
+		p.Min = MIN_CTN
+	}
+	if p.Max <= 0 {
+		p.Max = MAX_CTN
+	}
+	if p.LanguageTimeout <= 0 {
+		p.LanguageTimeout = LANGUAGE_TIMEOUT
+	}
+	if p.ContainerTimeout <= 0 {
+		p.ContainerTimeout = CONTAINER_TIMEOUT
+	}
+	if p.WaitTimeout <= 0 {
+		p.WaitTimeout = WAIT_TIMEOUT
+	}
+	return p
+}
+
+func (p PoolPolicy) String() string {
+	return fmt.Sprintf("min=%d,max=%d,language=%s,container=%s,wait=%s",
+		p.Min, p.Max, p.LanguageTimeout, p.ContainerTimeout, p.WaitTimeout)
+}
@@ -0,0 +1,155 @@
+package container
+
+import "fmt"
+
+const (
+	// number of submissions waiting for a container of an image
+	QUEUE_SIZE = 50
+	// number of submissions of a single client waiting for a container of an image
+	CLIENT_QUEUE_SIZE = 3
+)
+
+// Waiter identifies who asks for a container and how to notify them while they wait.
+type Waiter struct {
+	// Client is used to share the containers fairly, e.g. the IP of the learner
+	Client string
+	// OnQueue is called with the position in the queue each time it changes
+	OnQueue func(position int)
+}
+
+// BusyError is returned when no container could be handed out, either because
+// the queue is full or because the submission waited too long.
+type BusyError struct {
+	Position int
+}
+
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("Server busy, you are #%d in the queue", e.Position)
+}
+
+// ticket is a submission waiting in the queue.
+type ticket struct {
+	client string
+	// receives the container once it is this ticket's turn
+	ready chan grant
+	// receives the latest position, older positions are dropped
+	positions chan int
+}
+
+// grant is a container handed to a ticket.
+type grant struct {
+	id       string
+	extended bool
+}
+
+// queue holds the submissions waiting for a container. Clients are served
+// round-robin and the submissions of a client in order, so a single client
+// submitting in a loop cannot starve the others. It is not synchronized,
+// the owner must hold its lock.
+type queue struct {
+	// clients with waiting tickets, the first one is served next
+	clients []string
+	tickets map[string][]*ticket
+	size    int
+}
+
+func newQueue() queue {
+	return queue{tickets: make(map[string][]*ticket)}
+}
+
+// push adds a ticket for the client, or returns a BusyError if the queue is full.
+func (q *queue) push(client string) (*ticket, error) {
+	if q.size >= QUEUE_SIZE || len(q.tickets[client]) >= CLIENT_QUEUE_SIZE {
+		return nil, &BusyError{Position: q.size + 1}
+	}
+	t := &ticket{
+		client:    client,
+		ready:     make(chan grant, 1),
+		positions: make(chan int, 1),
+	}
+	if len(q.tickets[client]) == 0 {
+		q.clients = append(q.clients, client)
+	}
+	q.tickets[client] = append(q.tickets[client], t)
+	q.size++
+	q.notify()
+	return t, nil
+}
+
+// pop removes and returns the next ticket to serve, nil if the queue is empty.
+func (q *queue) pop() *ticket {
+	if q.size == 0 {
+		return nil
+	}
+	client := q.clients[0]
+	q.clients = q.clients[1:]
+	t := q.tickets[client][0]
+	q.tickets[client] = q.tickets[client][1:]
+	if len(q.tickets[client]) > 0 {
+		// serve the other clients before its next ticket
+		q.clients = append(q.clients, client)
+	} else {
+		delete(q.tickets, client)
+	}
+	q.size--
+	q.notify()
+	return t
+}
+
+// remove withdraws a ticket, returning false if it was already served.
+func (q *queue) remove(t *ticket) bool {
+	tickets := q.tickets[t.client]
+	for i, other := range tickets {
+		if other != t {
+			continue
+		}
+		q.tickets[t.client] = append(tickets[:i:i], tickets[i+1:]...)
+		if len(q.tickets[t.client]) == 0 {
+			delete(q.tickets, t.client)
+			for j, client := range q.clients {
+				if client == t.client {
+					q.clients = append(q.clients[:j:j], q.clients[j+1:]...)
+					break
+				}
+			}
+		}
+		q.size--
+		q.notify()
+		return true
+	}
+	return false
+}
+
+// order returns the tickets in the order they will be served.
+func (q *queue) order() []*ticket {
+	order := make([]*ticket, 0, q.size)
+	for round := 0; len(order) < q.size; round++ {
+		for _, client := range q.clients {
+			if round < len(q.tickets[client]) {
+				order = append(order, q.tickets[client][round])
+			}
+		}
+	}
+	return order
+}
+
+// position returns the position of the ticket, 0 if it is not in the queue.
+func (q *queue) position(t *ticket) int {
+	for i, other := range q.order() {
+		if other == t {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// notify sends its position to each ticket, replacing the one not read yet.
+func (q *queue) notify() {
+	for i, t := range q.order() {
+		select {
+		case <-t.positions:
+		default:
+		}
+		t.positions <- i + 1
+	}
+}
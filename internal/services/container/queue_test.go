@@ -0,0 +1,70 @@
+package container
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQueue_RoundRobin(t *testing.T) {
+	q := newQueue()
+	a1, _ := q.push("a")
+	a2, _ := q.push("a")
+	a3, _ := q.push("a")
+	b1, _ := q.push("b")
+	c1, _ := q.push("c")
+
+	if got := q.position(b1); got != 2 {
+		t.Errorf("expected b1 at position 2, got %d", got)
+	}
+	expected := []*ticket{a1, b1, c1, a2, a3}
+	for i, want := range expected {
+		if got := q.pop(); got != want {
+			t.Fatalf("pop %d: expected ticket of %s, got ticket of %s", i, want.client, got.client)
+		}
+	}
+	if q.pop() != nil {
+		t.Error("expected empty queue")
+	}
+}
+
+func TestQueue_Positions(t *testing.T) {
+	q := newQueue()
+	a1, _ := q.push("a")
+	b1, _ := q.push("b")
+	if got := <-b1.positions; got != 2 {
+		t.Errorf("expected position 2, got %d", got)
+	}
+
+	if !q.remove(a1) {
+		t.Fatal("expected a1 to be removed")
+	}
+	if got := <-b1.positions; got != 1 {
+		t.Errorf("expected position 1 after removal, got %d", got)
+	}
+	if q.remove(a1) {
+		t.Error("expected a1 to be already removed")
+	}
+}
+
+func TestQueue_Bounded(t *testing.T) {
+	q := newQueue()
+	for range CLIENT_QUEUE_SIZE {
+		if _, err := q.push("a"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	_, err := q.push("a")
+	var busy *BusyError
+	if !errors.As(err, &busy) || busy.Position != CLIENT_QUEUE_SIZE+1 {
+		t.Errorf("expected busy error at position %d, got %v", CLIENT_QUEUE_SIZE+1, err)
+	}
+
+	for i := q.size; i < QUEUE_SIZE; i++ {
+		if _, err := q.push(string(rune('b' + i))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := q.push("z"); !errors.As(err, &busy) {
+		t.Errorf("expected busy error on a full queue, got %v", err)
+	}
+}
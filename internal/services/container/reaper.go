@@ -0,0 +1,105 @@
+package container
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	// time between two passes of the reaper
+	REAP_INTERVAL = 5 * time.Minute
+	// age under which a container of this instance is considered still being
+	// created, as it is only tracked once the runtime returns its id
+	REAP_GRACE = time.Minute
+	// time between two heartbeats of the instance
+	HEARTBEAT_INTERVAL = 30 * time.Second
+	// time without heartbeat after which another instance is considered gone
+	INSTANCE_TIMEOUT = 4 * HEARTBEAT_INTERVAL
+)
+
+// Reap removes the sandbox containers not owned by this instance, like the
+// ones left behind by a crash. The containers of another instance still
+// sending heartbeats are left alone, like during a restart where the previous
+// server drains its runs. Returns the number of removed containers.
+func Reap(ctx context.Context, rt Runtime) (int, error) {
+	instances, err := rt.Instances(ctx)
+	if err != nil {
+		return 0, err
+	}
+	live := func(instance string) bool {
+		at, ok := instances[instance]
+		return ok && time.Since(at) < INSTANCE_TIMEOUT
+	}
+	ctns, err := rt.List(ctx, map[string]string{LABEL_APP: App})
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, ctn := range ctns {
+		if !isOrphan(ctn, live) {
+			continue
+		}
+		removeContainer(ctx, rt, ctn.ID)
+		removed++
+	}
+	for instance := range instances {
+		if instance != Instance && !live(instance) {
+			if err := rt.ForgetInstance(ctx, instance); err != nil {
+				log.Printf("Failed to remove the heartbeats of instance %s: %v", instance, err)
+			}
+		}
+	}
+	return removed, nil
+}
+
+// isOrphan tells whether a labelled container is neither owned by this
+// instance nor by another live one.
+func isOrphan(ctn ContainerInfo, live func(instance string) bool) bool {
+	instance := ctn.Labels[LABEL_INSTANCE]
+	if instance == Instance && isOwned(ctn.ID) {
+		return false
+	}
+	if instance != Instance && live(instance) {
+		return false
+	}
+	// an instance creates containers right before its first heartbeat
+	createdAt, err := time.Parse(time.RFC3339, ctn.Labels[LABEL_CREATED_AT])
+	return err != nil || time.Since(createdAt) > REAP_GRACE
+}
+
+// RunReaper reaps once right away, then every interval until the context is done.
+func RunReaper(ctx context.Context, rt Runtime, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		removed, err := Reap(ctx, rt)
+		if err != nil {
+			log.Printf("Failed to reap orphan containers: %v", err)
+		} else if removed > 0 {
+			log.Printf("Removed %d orphan containers", removed)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunHeartbeat records this instance as alive right away, then every interval
+// until the context is done.
+func RunHeartbeat(ctx context.Context, rt Runtime, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := rt.Heartbeat(ctx, Instance, time.Now()); err != nil {
+			log.Printf("Failed to record the heartbeat: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
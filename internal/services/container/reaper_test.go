@@ -0,0 +1,67 @@
+package container_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"nexzap/internal/services/container"
+)
+
+func TestReap_RemovesOrphans(t *testing.T) {
+	ctx := context.Background()
+	rt := container.NewFakeRuntime(echoHandler)
+	pool := container.NewPool()
+	defer pool.CleanAll(ctx, rt)
+	pool.GetImagePool(ctx, rt, container.Tutorial{Image: "gotest"})
+	owned := rt.Containers()
+
+	hourAgo := time.Now().Add(-time.Hour)
+	// Left by a previous instance of the server, its heartbeats stopped
+	crashed := container.Labels("gotest", container.PoolMin)
+	crashed[container.LABEL_INSTANCE] = "crashed"
+	crashed[container.LABEL_CREATED_AT] = hourAgo.Format(time.RFC3339)
+	orphan, _ := rt.Create(ctx, container.Tutorial{Image: "gotest"}, crashed)
+	rt.Heartbeat(ctx, "crashed", hourAgo)
+	// Of another instance still running, like a server draining its runs
+	running := container.Labels("gotest", container.PoolMin)
+	running[container.LABEL_INSTANCE] = "running"
+	running[container.LABEL_CREATED_AT] = hourAgo.Format(time.RFC3339)
+	foreign, _ := rt.Create(ctx, container.Tutorial{Image: "gotest"}, running)
+	rt.Heartbeat(ctx, "running", time.Now())
+	// Leaked by this instance a while ago
+	old := container.Labels("gotest", container.PoolExtended)
+	old[container.LABEL_CREATED_AT] = hourAgo.Format(time.RFC3339)
+	leaked, _ := rt.Create(ctx, container.Tutorial{Image: "gotest"}, old)
+	// Being created by this instance
+	fresh, _ := rt.Create(ctx, container.Tutorial{Image: "gotest"}, container.Labels("gotest", container.PoolMin))
+	// Not a sandbox
+	other, _ := rt.Create(ctx, container.Tutorial{Image: "postgres"}, nil)
+
+	removed, err := container.Reap(ctx, rt)
+	if err != nil {
+		t.Fatalf("Reap failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 removed containers, got %d", removed)
+	}
+	alive := rt.Containers()
+	for _, ctn := range append(owned, fresh, other, foreign) {
+		if !slices.Contains(alive, ctn) {
+			t.Errorf("container %s should not have been removed", ctn)
+		}
+	}
+	for _, ctn := range []string{orphan, leaked} {
+		if slices.Contains(alive, ctn) {
+			t.Errorf("orphan container %s should have been removed", ctn)
+		}
+	}
+	instances, _ := rt.Instances(ctx)
+	if _, ok := instances["crashed"]; ok {
+		t.Errorf("expected the heartbeats of the crashed instance removed")
+	}
+	if _, ok := instances["running"]; !ok {
+		t.Errorf("expected the heartbeats of the running instance kept")
+	}
+}
@@ -0,0 +1,27 @@
+package container
+
+import "fmt"
+
+// ResetPolicy defines how a container is cleaned before being handed to the next run.
+type ResetPolicy string
+
+const (
+	// ResetRecreate removes the used container and replaces it with a new one
+	// created from the image, so no file of a run survives into the next one.
+	ResetRecreate ResetPolicy = "recreate"
+	// ResetNone puts the container back as is. Files written by a run, including
+	// build artifacts, are visible to the next run. Only meant for development.
+	ResetNone ResetPolicy = "none"
+)
+
+// ParseResetPolicy parses a policy name, an empty name being the safe default.
+func ParseResetPolicy(name string) (ResetPolicy, error) {
+	switch ResetPolicy(name) {
+	case "", ResetRecreate:
+		return ResetRecreate, nil
+	case ResetNone:
+		return ResetNone, nil
+	default:
+		return "", fmt.Errorf("unknown reset policy %q", name)
+	}
+}
@@ -0,0 +1,66 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned by Runtime.Inspect for a container that doesn't exist.
+var ErrNotFound = errors.New("no such container")
+
+// Runtime abstracts the container engine that runs the submissions.
+// The pool and the runner only talk to this interface, so Docker can be
+// swapped for another OCI runtime or for the in-process fake used in tests.
+type Runtime interface {
+	// Create creates a stopped container for the tutorial with the labels and returns its id.
+	Create(ctx context.Context, tutorial Tutorial, labels map[string]string) (string, error)
+	// CopyFiles copies the files in the workspace of the container.
+	CopyFiles(ctx context.Context, id string, files []File) error
+	// Start starts the command of the container.
+	Start(ctx context.Context, id string) error
+	// Wait blocks until the container is not running anymore.
+	Wait(ctx context.Context, id string) (RunResponse, error)
+	// Kill stops the command of the container right away. Does nothing when
+	// the container is not running.
+	Kill(ctx context.Context, id string) error
+	// Logs returns the stdout and stderr of the container, multiplexed the same
+	// way as the Docker API.
+	Logs(ctx context.Context, id string, opts LogsOptions) (io.ReadCloser, error)
+	// Remove stops and removes the container. Errors are only logged.
+	Remove(ctx context.Context, id string)
+	// Inspect returns the state of the container.
+	Inspect(ctx context.Context, id string) (ContainerState, error)
+	// List returns the containers, running or not, having all the labels.
+	List(ctx context.Context, labels map[string]string) ([]ContainerInfo, error)
+	// Exec executes a command in the directory of a run of a running container,
+	// see ModeExec. It returns the id of the execution and its stdout and
+	// stderr, multiplexed like the logs, until the command exits.
+	Exec(ctx context.Context, id string, opts ExecOptions) (string, io.ReadCloser, error)
+	// ExecWait blocks until the execution is over.
+	ExecWait(ctx context.Context, execID string) (RunResponse, error)
+	// CleanRun kills what the run left running and removes its directories.
+	CleanRun(ctx context.Context, id string, dir string) error
+	// Heartbeat records the instance as alive at the given time, for the
+	// reapers of the other instances using the same host. See Reap.
+	Heartbeat(ctx context.Context, instance string, at time.Time) error
+	// Instances returns the time of the last heartbeat of each instance.
+	Instances(ctx context.Context) (map[string]time.Time, error)
+	// ForgetInstance removes the heartbeats of an instance.
+	ForgetInstance(ctx context.Context, instance string) error
+}
+
+// ContainerInfo describes a container returned by Runtime.List.
+type ContainerInfo struct {
+	ID     string
+	Labels map[string]string
+}
+
+// LogsOptions selects the logs returned by Runtime.Logs.
+type LogsOptions struct {
+	// Since only keeps the logs produced after this time.
+	Since time.Time
+	// Follow keeps the stream open until the container stops.
+	Follow bool
+}
@@ -0,0 +1,116 @@
+package container
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// user running the commands when not set by the tutorial, anything but root
+	DEFAULT_SANDBOX_USER = "1000:1000"
+	// size of the workspace, and of /tmp, when not set by the tutorial
+	DEFAULT_WORKSPACE_SIZE = 256 * 1024 * 1024
+	// open files of a process when not set by the tutorial
+	DEFAULT_NOFILE = 1024
+	// processes of the user when not set by the tutorial. The kernel counts them
+	// on the whole host, the pids limit is the one bounding each container.
+	DEFAULT_NPROC = 4096
+
+	// WORKSPACE is the working directory of the commands, a tmpfs
+	WORKSPACE = "/workspace"
+	// INBOX receives the files of a run. A tmpfs only exists while the container
+	// runs, so the files are copied here and moved to the workspace on start.
+	INBOX = "/nexzap/inbox"
+)
+
+// Sandbox hardens the containers of a tutorial. Zero values mean the default.
+type Sandbox struct {
+	// User running the commands, as "uid" or "uid:gid"
+	User string
+	// Writable keeps the root filesystem writable, for the images that can't
+	// do without. Otherwise only the workspace and /tmp are.
+	Writable bool
+	// WorkspaceSize in bytes of the tmpfs of the workspace, /tmp gets as much
+	WorkspaceSize int64
+	NoFile        int64
+	NProc         int64
+	// Seccomp is a seccomp profile in JSON, the default one of Docker when empty
+	Seccomp string
+}
+
+// WithDefaults returns the sandbox with the unset values replaced by the defaults.
+func (s Sandbox) WithDefaults() Sandbox {
+	if s.User == "" {
+		s.User = DEFAULT_SANDBOX_USER
+	}
+	if s.WorkspaceSize <= 0 {
+		s.WorkspaceSize = DEFAULT_WORKSPACE_SIZE
+	}
+	if s.NoFile <= 0 {
+		s.NoFile = DEFAULT_NOFILE
+	}
+	if s.NProc <= 0 {
+		s.NProc = DEFAULT_NPROC
+	}
+	return s
+}
+
+// Validate checks the values set by a tutorial.
+func (s Sandbox) Validate() error {
+	if s.User != "" {
+		if _, _, err := s.ids(); err != nil {
+			return err
+		}
+	}
+	if s.WorkspaceSize < 0 || s.NoFile < 0 || s.NProc < 0 {
+		return errors.New("workspace_size, nofile and nproc must be positive")
+	}
+	if s.Seccomp != "" && !json.Valid([]byte(s.Seccomp)) {
+		return errors.New("the seccomp profile is not valid JSON")
+	}
+	return nil
+}
+
+// ids returns the uid and gid of the user, the gid being the uid when unset.
+// Names are refused as they depend on the /etc/passwd of the image.
+func (s Sandbox) ids() (int, int, error) {
+	uid, gid, found := strings.Cut(s.User, ":")
+	if !found {
+		gid = uid
+	}
+	u, err := strconv.Atoi(uid)
+	if err != nil || u < 0 {
+		return 0, 0, fmt.Errorf("user %q is not a numeric uid[:gid]", s.User)
+	}
+	g, err := strconv.Atoi(gid)
+	if err != nil || g < 0 {
+		return 0, 0, fmt.Errorf("user %q is not a numeric uid[:gid]", s.User)
+	}
+	return u, g, nil
+}
+
+func (s Sandbox) String() string {
+	seccomp := "default"
+	if s.Seccomp != "" {
+		seccomp = fmt.Sprintf("%x", sha256.Sum256([]byte(s.Seccomp)))[:12]
+	}
+	return fmt.Sprintf("user=%s,writable=%t,workspace=%d,nofile=%d,nproc=%d,seccomp=%s",
+		s.User, s.Writable, s.WorkspaceSize, s.NoFile, s.NProc, seccomp)
+}
+
+// tmpfs returns the options of a tmpfs owned by the user of the sandbox.
+func (s Sandbox) tmpfs() string {
+	uid, gid, _ := s.ids()
+	return fmt.Sprintf("rw,exec,nosuid,nodev,size=%d,uid=%d,gid=%d,mode=0755", s.WorkspaceSize, uid, gid)
+}
+
+// command wraps the command of a tutorial so it first moves the files of the
+// inbox to the workspace and copies the caches.
+func (s Sandbox) command(cmd []string, caches []Cache) []string {
+	script := fmt.Sprintf(`%scp -R %s/. %s/ && exec "$@"`, copyCaches(CACHE_DIR, caches), INBOX, WORKSPACE)
+	return append([]string{"sh", "-c", script, "sh"}, cmd...)
+}
@@ -0,0 +1,48 @@
+package container_test
+
+import (
+	"strings"
+	"testing"
+
+	"nexzap/internal/services/container"
+)
+
+func TestSandbox_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		sandbox container.Sandbox
+		valid   bool
+	}{
+		{"defaults", container.Sandbox{}, true},
+		{"uid", container.Sandbox{User: "1000"}, true},
+		{"uid and gid", container.Sandbox{User: "1000:100"}, true},
+		// names depend on the image
+		{"name", container.Sandbox{User: "nobody"}, false},
+		{"negative gid", container.Sandbox{User: "1000:-1"}, false},
+		{"negative size", container.Sandbox{WorkspaceSize: -1}, false},
+		{"seccomp", container.Sandbox{Seccomp: `{"defaultAction": "SCMP_ACT_ERRNO"}`}, true},
+		{"invalid seccomp", container.Sandbox{Seccomp: `{"defaultAction"`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sandbox.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("expected valid %v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestSandbox_WithDefaults(t *testing.T) {
+	sandbox := container.Sandbox{User: "2000"}.WithDefaults()
+	if sandbox.User != "2000" || sandbox.WorkspaceSize != container.DEFAULT_WORKSPACE_SIZE ||
+		sandbox.NoFile != container.DEFAULT_NOFILE || sandbox.NProc != container.DEFAULT_NPROC {
+		t.Errorf("unexpected sandbox %+v", sandbox)
+	}
+	if err := sandbox.Validate(); err != nil {
+		t.Errorf("expected the defaults to be valid, got %v", err)
+	}
+	if strings.HasPrefix(container.Sandbox{}.WithDefaults().User, "0") {
+		t.Error("expected the default user not to be root")
+	}
+}
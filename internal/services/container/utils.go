@@ -1,42 +1,56 @@
 package container
 
 import (
-	"log"
+	"sync"
 	"time"
 )
 
-// Timeout manages a timer with an associated action to be executed on expiration.
+// Timeout runs an action once its duration has passed since it was last
+// started. Restarting or stopping it cancels the pending action, and nothing
+// waits on the timer in between: the action gets a goroutine when it fires.
 type Timeout struct {
+	mu       sync.Mutex
 	timer    *time.Timer
 	duration time.Duration
 	action   func()
+	// incremented on each start and stop, an expiration of an older timer is ignored
+	generation uint64
 }
 
 // NewTimeout creates a new Timeout instance with the specified duration and action.
 func NewTimeout(duration time.Duration, action func()) *Timeout {
-	t := &Timeout{
-		timer:    time.NewTimer(duration),
-		duration: duration,
-		action:   action,
-	}
-	return t
+	return &Timeout{duration: duration, action: action}
 }
 
 // StartTimer starts or resets the timer for the Timeout.
 func (t *Timeout) StartTimer() {
-	if !t.timer.Stop() {
-		select {
-		case <-t.timer.C:
-			log.Fatalf("Timer should already been drained")
-		default:
-		}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.generation++
+	generation := t.generation
+	t.timer = time.AfterFunc(t.duration, func() { t.fire(generation) })
+}
+
+// Stop cancels the pending action. An action already running is not interrupted.
+func (t *Timeout) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
 	}
-	t.timer.Reset(t.duration)
+	t.generation++
+}
 
-	go func() {
-		<-t.timer.C
-		if t.action != nil {
-			t.action()
-		}
-	}()
+// fire runs the action if the timer has not been restarted nor stopped since.
+// The lock is released first, so the action can stop the timeout.
+func (t *Timeout) fire(generation uint64) {
+	t.mu.Lock()
+	current := generation == t.generation
+	t.mu.Unlock()
+	if current && t.action != nil {
+		t.action()
+	}
 }
@@ -0,0 +1,43 @@
+package container_test
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nexzap/internal/services/container"
+)
+
+func TestTimeout_RestartFiresOnce(t *testing.T) {
+	var fired atomic.Int32
+	timeout := container.NewTimeout(20*time.Millisecond, func() { fired.Add(1) })
+
+	before := runtime.NumGoroutine()
+	for range 1000 {
+		timeout.StartTimer()
+	}
+	if after := runtime.NumGoroutine(); after > before+10 {
+		t.Errorf("expected no goroutine per restart, went from %d to %d", before, after)
+	}
+	waitFor(t, "the timeout to fire", func() bool { return fired.Load() > 0 })
+	time.Sleep(50 * time.Millisecond)
+	if got := fired.Load(); got != 1 {
+		t.Errorf("expected the action to run once, ran %d times", got)
+	}
+
+	// It can be started again once fired
+	timeout.StartTimer()
+	waitFor(t, "the timeout to fire again", func() bool { return fired.Load() == 2 })
+}
+
+func TestTimeout_Stop(t *testing.T) {
+	var fired atomic.Int32
+	timeout := container.NewTimeout(10*time.Millisecond, func() { fired.Add(1) })
+	timeout.StartTimer()
+	timeout.Stop()
+	time.Sleep(50 * time.Millisecond)
+	if got := fired.Load(); got != 0 {
+		t.Errorf("expected a stopped timeout not to fire, ran %d times", got)
+	}
+}
@@ -1,23 +1,79 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"nexzap/internal/metrics"
 	"nexzap/internal/services/container"
+	"nexzap/internal/services/judge"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	generated "nexzap/internal/db/generated"
 
-	"github.com/docker/docker/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+var runDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "nexzap_run_duration_seconds",
+	Help:    "Duration of the submissions, from the wait for a container to the end of the run, by image and outcome.",
+	Buckets: metrics.DefaultBuckets,
+}, []string{"image", "outcome"})
+
+// ErrNotEditable is returned for a submission overwriting a file the learner can't edit.
+var ErrNotEditable = errors.New("file not editable")
+
+// ErrNoScratchpad is returned for the code run in the scratchpad of a tutorial without run command.
+var ErrNoScratchpad = errors.New("This tutorial has no scratchpad")
+
+// Limits of the scratchpad, stricter than the ones of the sheet since the code is not graded
+const (
+	SCRATCH_MEMORY       = 256 * 1024 * 1024
+	SCRATCH_TIMEOUT      = 10 * time.Second
+	SCRATCH_PIDS_LIMIT   = 64
+	SCRATCH_OUTPUT_LIMIT = 64 * 1024
+	// file given on stdin to the run command
+	SCRATCH_STDIN = judge.DIR + "/stdin"
+)
+
+// A run failing for another reason than its limits is retried on another container
+const (
+	RUN_ATTEMPTS = 3
+	RETRY_DELAY  = 3 * time.Second
+)
+
+// ScratchResult is the outcome of code run in the scratchpad.
+type ScratchResult struct {
+	Stdout     string
+	Stderr     string
+	StatusCode int64
+	// the run was stopped by the time limit
+	TimedOut bool
+	// the run was stopped by the output limit
+	Truncated bool
+}
+
+// ErrShuttingDown is returned for the submissions received once the service is draining.
+var ErrShuttingDown = errors.New("Server is restarting, please submit again in a moment")
+
 // ExerciseService encapsulates the state and operations for language testing services.
 type ExerciseService struct {
 	pool        container.Pool
 	ctx         context.Context
-	cli         *client.Client
+	rt          container.Runtime
 	initialized bool
+	stopReaper  context.CancelFunc
+	// runs in flight, waited for when draining
+	runs     sync.WaitGroup
+	mu       sync.Mutex
+	draining bool
 }
 
 // NewExerciseService creates and initializes a new Service instance.
@@ -29,70 +85,524 @@ func NewExerciseService() (*ExerciseService, error) {
 	return svc, nil
 }
 
-// init initializes the service, setting up the container pool and Docker client.
+// NewExerciseServiceWithRuntime creates a service running the containers on the given runtime.
+func NewExerciseServiceWithRuntime(rt container.Runtime) *ExerciseService {
+	svc := &ExerciseService{rt: rt}
+	svc.pool = container.NewPool()
+	svc.ctx = context.Background()
+	svc.initialized = true
+	return svc
+}
+
+// init initializes the service, setting up the container pool and Docker runtime.
 func (s *ExerciseService) init() error {
 	s.pool = container.NewPool()
 	s.ctx = context.Background()
 	var err error
-	s.cli, err = client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	// On the worker nodes of DOCKER_NODES when set, else on the local host
+	s.rt, err = container.NewEngineFromEnv()
 	if err != nil {
 		return err
 	}
+	// Containers are recreated between runs unless disabled for development
+	reset, err := container.ParseResetPolicy(os.Getenv("CONTAINER_RESET"))
+	if err != nil {
+		return err
+	}
+	s.pool.SetResetPolicy(reset)
+	s.pool.ExportMetrics()
+	// Remove the containers left by a previous crash, then periodically the leaked ones
+	var reaperCtx context.Context
+	reaperCtx, s.stopReaper = context.WithCancel(s.ctx)
+	// Tells the reapers of the other instances on the host to leave ours alone
+	go container.RunHeartbeat(reaperCtx, s.rt, container.HEARTBEAT_INTERVAL)
+	go container.RunReaper(reaperCtx, s.rt, container.REAP_INTERVAL)
+	go s.pool.RunHealthChecks(reaperCtx, s.rt, container.HEALTH_INTERVAL)
+	if nodes, ok := s.rt.(*container.MultiRuntime); ok {
+		go nodes.RunNodeChecks(reaperCtx, container.HEALTH_INTERVAL)
+	}
 	s.initialized = true
 	return nil
 }
 
+// SetPoolPolicy sets how the container pool of each image is sized.
+func (s *ExerciseService) SetPoolPolicy(policy container.PoolPolicyFunc) {
+	s.pool.SetPoolPolicy(policy)
+}
+
 type Correction = generated.FindSubmissionDataRow
 
 // RunTest executes the provided files in test mode for a given language.
-func (s *ExerciseService) RunTest(correction Correction, payload string) (string, container.RunResponse, error) {
+// The submission holds the content of the editable files by name.
+// The waiter identifies the submitter while waiting for a container.
+func (s *ExerciseService) RunTest(
+	correction Correction,
+	submission map[string]string,
+	waiter container.Waiter,
+) (container.Output, container.RunResponse, error) {
+	if err := s.begin(); err != nil {
+		return container.Output{}, container.RunResponse{}, err
+	}
+	defer s.runs.Done()
+	start := time.Now()
+	output, status, err := s.runTest(correction, submission, waiter)
+	observeRun(correction.DockerImage, start, status, err)
+	return output, status, err
+}
+
+func (s *ExerciseService) runTest(
+	correction Correction,
+	submission map[string]string,
+	waiter container.Waiter,
+) (container.Output, container.RunResponse, error) {
 	if !s.initialized {
-		return "", container.RunResponse{}, fmt.Errorf("not initialized")
+		return container.Output{}, container.RunResponse{}, fmt.Errorf("not initialized")
+	}
+	grader := judgeFor(correction)
+	tutorial, files, err := s.prepare(correction, submission, grader)
+	if err != nil {
+		return container.Output{}, container.RunResponse{}, err
+	}
+
+	languagePool := s.pool.GetImagePool(s.ctx, s.rt, tutorial)
+	var lastErr error
+	for attempt := range RUN_ATTEMPTS {
+		// Wait before retrying
+		if attempt > 0 {
+			time.Sleep(RETRY_DELAY)
+		}
+		ctn, err := languagePool.GetContainer(s.ctx, s.rt, waiter)
+		if err != nil {
+			return container.Output{}, container.RunResponse{}, err
+		}
+		timeoutCtx, cancel := context.WithTimeout(s.ctx, tutorial.Limits.Timeout)
+
+		var recorder container.OutputRecorder
+		status, err := s.stream(timeoutCtx, tutorial, ctn, files, recorder.Writer(container.STDOUT), recorder.Writer(container.STDERR))
+		output := recorder.Output()
+		languagePool.FreeContainer(s.ctx, s.rt, ctn)
+		isTimeout := timedOut(timeoutCtx, err)
+		cancel()
+		// Not retried, the submission would reach the time limit again
+		if isTimeout {
+			output, _ = grade(grader, output, status)
+			return output, timeoutStatus, nil
+		}
+		if err == nil {
+			output, status = grade(grader, output, status)
+			return output, status, nil
+		}
+		log.Printf("Attempt %d failed: %v", attempt, err)
+		lastErr = err
+	}
+	return container.Output{}, container.RunResponse{}, lastErr
+}
+
+// RunTestStream executes the provided files in test mode and writes the output as it is produced.
+// Unlike RunTest it is never retried, since part of the output may already have been sent.
+func (s *ExerciseService) RunTestStream(
+	correction Correction,
+	submission map[string]string,
+	waiter container.Waiter,
+	stdout, stderr io.Writer,
+) (container.RunResponse, error) {
+	if err := s.begin(); err != nil {
+		return container.RunResponse{}, err
+	}
+	defer s.runs.Done()
+	start := time.Now()
+	status, err := s.runTestStream(correction, submission, waiter, stdout, stderr)
+	observeRun(correction.DockerImage, start, status, err)
+	return status, err
+}
+
+func (s *ExerciseService) runTestStream(
+	correction Correction,
+	submission map[string]string,
+	waiter container.Waiter,
+	stdout, stderr io.Writer,
+) (container.RunResponse, error) {
+	if !s.initialized {
+		return container.RunResponse{}, fmt.Errorf("not initialized")
+	}
+	grader := judgeFor(correction)
+	tutorial, files, err := s.prepare(correction, submission, grader)
+	if err != nil {
+		return container.RunResponse{}, err
+	}
+
+	languagePool := s.pool.GetImagePool(s.ctx, s.rt, tutorial)
+	ctn, err := languagePool.GetContainer(s.ctx, s.rt, waiter)
+	if err != nil {
+		return container.RunResponse{}, err
+	}
+	timeoutCtx, cancel := context.WithTimeout(s.ctx, tutorial.Limits.Timeout)
+	defer cancel()
+
+	if grader == nil {
+		status, err := s.stream(timeoutCtx, tutorial, ctn, files, stdout, stderr)
+		languagePool.FreeContainer(s.ctx, s.rt, ctn)
+		if timedOut(timeoutCtx, err) {
+			return timeoutStatus, nil
+		}
+		return status, err
+	}
+	// The raw output of a graded sheet is only meant for the judge
+	var output bytes.Buffer
+	status, err := s.stream(timeoutCtx, tutorial, ctn, files, &output, &output)
+	languagePool.FreeContainer(s.ctx, s.rt, ctn)
+	isTimeout := timedOut(timeoutCtx, err)
+	if err != nil && !isTimeout {
+		return status, err
+	}
+	graded, status := grade(grader, container.NewOutput(output.String(), ""), status)
+	if isTimeout {
+		status = timeoutStatus
+	}
+	_, err = io.WriteString(stdout, graded.Stdout)
+	return status, err
+}
+
+// stream runs the files in a container of the pool of the tutorial, the way
+// set by its mode.
+func (s *ExerciseService) stream(
+	ctx context.Context,
+	tutorial container.Tutorial,
+	ctn string,
+	files []container.File,
+	stdout, stderr io.Writer,
+) (container.RunResponse, error) {
+	if tutorial.Mode == container.ModeExec {
+		return container.StreamExec(ctx, s.rt, ctn, tutorial, files, stdout, stderr)
+	}
+	return container.Stream(ctx, s.rt, ctn, files, tutorial.Limits.OutputLimit, stdout, stderr)
+}
+
+// timeoutStatus is the status of a run stopped by the time limit.
+var timeoutStatus = container.RunResponse{StatusCode: container.STATUS_KILLED, TimedOut: true}
+
+// timedOut tells whether a run failed because the deadline of its context was reached.
+func timedOut(ctx context.Context, err error) bool {
+	return err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// RunScratch runs the files of the submission with the run command of the tutorial,
+// for the learners to try some code. Neither the correction files nor any test
+// are used, and the limits are stricter than the ones of the sheet.
+func (s *ExerciseService) RunScratch(
+	correction Correction,
+	submission map[string]string,
+	stdin string,
+	waiter container.Waiter,
+) (ScratchResult, error) {
+	if err := s.begin(); err != nil {
+		return ScratchResult{}, err
+	}
+	defer s.runs.Done()
+	if !s.initialized {
+		return ScratchResult{}, fmt.Errorf("not initialized")
+	}
+	if correction.TutorialRunCommand == "" {
+		return ScratchResult{}, ErrNoScratchpad
+	}
+	files, err := submittedFiles(correction, submission)
+	if err != nil {
+		return ScratchResult{}, err
+	}
+	files = append(files, container.File{Name: SCRATCH_STDIN, Content: stdin})
+	tutorial := scratchTutorial(correction)
+
+	languagePool := s.pool.GetImagePool(s.ctx, s.rt, tutorial)
+	ctn, err := languagePool.GetContainer(s.ctx, s.rt, waiter)
+	if err != nil {
+		return ScratchResult{}, err
+	}
+	timeoutCtx, cancel := context.WithTimeout(s.ctx, tutorial.Limits.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	status, err := s.stream(timeoutCtx, tutorial, ctn, files, &stdout, &stderr)
+	// checked before freeing the container, which may outlast the deadline
+	isTimeout := timedOut(timeoutCtx, err)
+	languagePool.FreeContainer(s.ctx, s.rt, ctn)
+	result := ScratchResult{
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		StatusCode: status.StatusCode,
+		Truncated:  status.OutputTruncated,
+		TimedOut:   isTimeout,
 	}
+	if isTimeout {
+		return result, nil
+	}
+	return result, err
+}
+
+// scratchTutorial returns the container configuration of the scratchpad of
+// the sheet. Its containers are only created when needed.
+func scratchTutorial(correction Correction) container.Tutorial {
+	limits := sheetLimits(correction)
+	limits.Memory = min(limits.Memory, SCRATCH_MEMORY)
+	limits.Timeout = min(limits.Timeout, SCRATCH_TIMEOUT)
+	limits.PidsLimit = min(limits.PidsLimit, SCRATCH_PIDS_LIMIT)
+	limits.OutputLimit = min(limits.OutputLimit, SCRATCH_OUTPUT_LIMIT)
+	return container.Tutorial{
+		Image:    correction.DockerImage,
+		Digest:   correction.ImageDigest,
+		Command:  []string{"sh", "-c", correction.TutorialRunCommand + " < " + SCRATCH_STDIN},
+		Limits:   limits,
+		Sandbox:  tutorialSandbox(correction),
+		Caches:   tutorialCaches(correction),
+		Mode:     container.RunMode(correction.PoolMode),
+		OnDemand: true,
+	}
+}
+
+// begin registers a run in flight, unless the service is draining.
+func (s *ExerciseService) begin() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return ErrShuttingDown
+	}
+	s.runs.Add(1)
+	return nil
+}
+
+// Drain stops accepting new runs and waits for the runs in flight to finish.
+// Returns the error of the context if it is done before.
+func (s *ExerciseService) Drain(ctx context.Context) error {
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// observeRun records the duration and the outcome of a submission.
+func observeRun(image string, start time.Time, status container.RunResponse, err error) {
+	outcome := "passed"
+	var busy *container.BusyError
+	switch {
+	case errors.As(err, &busy):
+		outcome = "busy"
+	case err != nil:
+		outcome = "error"
+	case status.StatusCode != 0:
+		outcome = "failed"
+	}
+	runDuration.WithLabelValues(image, outcome).Observe(time.Since(start).Seconds())
+}
+
+// prepare builds the tutorial and the files to copy, with the files of the
+// submission replacing the editable ones. An editable file missing from the
+// submission keeps its starter content, so the correction is never run instead.
+func (s *ExerciseService) prepare(
+	correction Correction,
+	submission map[string]string,
+	grader *judge.Run,
+) (container.Tutorial, []container.File, error) {
 	tutorial := container.Tutorial{
 		Image:   correction.DockerImage,
+		Digest:  correction.ImageDigest,
 		Command: strings.Split(correction.Command, " "),
+		Limits:  sheetLimits(correction),
+		Sandbox: tutorialSandbox(correction),
+		Caches:  tutorialCaches(correction),
+		Mode:    container.RunMode(correction.PoolMode),
 	}
 
-	files := []container.File{}
+	files, err := submittedFiles(correction, submission)
+	if err != nil {
+		return tutorial, nil, err
+	}
+	editable := editableFiles(correction)
 	for i, name := range correction.FilesName {
-		if name != correction.SubmissionName {
+		if _, ok := editable[name]; !ok {
 			files = append(files, container.File{
-				Name:    correction.FilesName[i],
+				Name:    name,
 				Content: correction.FilesContent[i],
 			})
 		}
 	}
-	files = append(files, container.File{
-		Name:    correction.SubmissionName,
-		Content: payload,
-	})
+	if grader != nil {
+		tutorial.Command = judge.Command()
+		files = append(files, grader.Files()...)
+	}
+	return tutorial, files, nil
+}
 
-	languagePool := s.pool.GetImagePool(s.ctx, s.cli, tutorial)
-	ctn, err := languagePool.GetContainer(s.ctx, s.cli)
-	if err != nil {
-		return "", container.RunResponse{}, err
+func sheetLimits(correction Correction) container.Limits {
+	return container.Limits{
+		Memory:      correction.Memory,
+		CPUs:        correction.Cpus,
+		Timeout:     time.Duration(correction.Timeout) * time.Millisecond,
+		PidsLimit:   correction.PidsLimit,
+		OutputLimit: correction.OutputLimit,
+	}.WithDefaults()
+}
+
+// tutorialSandbox returns the sandbox set by the tutorial of the sheet.
+func tutorialSandbox(correction Correction) container.Sandbox {
+	return container.Sandbox{
+		User:          correction.SandboxUser,
+		Writable:      correction.SandboxWritable,
+		WorkspaceSize: correction.SandboxWorkspaceSize,
+		NoFile:        correction.SandboxNofile,
+		NProc:         correction.SandboxNproc,
+		Seccomp:       correction.SandboxSeccomp,
 	}
-	for attempt := range 3 {
-		timeoutCtx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
-		defer cancel()
+}
 
-		output, status, err := container.Run(timeoutCtx, s.cli, ctn, files)
-		languagePool.FreeContainer(s.ctx, s.cli, ctn)
-		if err == nil {
-			return output, status, nil
+// tutorialCaches returns the build caches of the image of the tutorial.
+func tutorialCaches(correction Correction) []container.Cache {
+	caches := []container.Cache{}
+	for i, path := range correction.CachePaths {
+		caches = append(caches, container.Cache{Path: path, Env: correction.CacheEnvs[i]})
+	}
+	return caches
+}
+
+// submittedFiles returns the editable files, with the content of the submission or else their starter.
+func submittedFiles(correction Correction, submission map[string]string) ([]container.File, error) {
+	editable := editableFiles(correction)
+	for name := range submission {
+		if _, ok := editable[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotEditable, name)
 		}
-		fmt.Printf("Attempt %d failed: %v\n", attempt, err)
-		ctn, err = languagePool.GetContainer(s.ctx, s.cli)
-		if err != nil {
-			return "", container.RunResponse{}, err
+	}
+	files := []container.File{}
+	for name, starter := range editable {
+		content, ok := submission[name]
+		if !ok {
+			content = starter
 		}
-		// Wait before retrying, but only if this isn't the last attempt
-		if attempt < 3 {
-			time.Sleep(3 * time.Second)
+		files = append(files, container.File{Name: name, Content: content})
+	}
+	return files, nil
+}
+
+// editableFiles returns the starter content of the files the learner edits, by name.
+// The submission file of the sheet is always editable.
+func editableFiles(correction Correction) map[string]string {
+	editable := map[string]string{correction.SubmissionName: ""}
+	for i, name := range correction.FilesName {
+		if i < len(correction.FilesEditable) && correction.FilesEditable[i] {
+			editable[name] = correction.FilesStarter[i]
+		}
+	}
+	return editable
+}
+
+// Grading tells how the submissions of a sheet are checked.
+type Grading string
+
+const (
+	// the test command must exit with 0
+	GradingTest Grading = "test"
+	// the output of the submission is compared with the expected one for each case
+	GradingIO Grading = "io"
+)
+
+// judgeFor returns the grading of a sheet checked on its output, nil for the others.
+// The command of the sheet builds the submission and its run command runs it.
+func judgeFor(correction Correction) *judge.Run {
+	if Grading(correction.Grading) != GradingIO {
+		return nil
+	}
+	cases := make([]judge.Case, 0, len(correction.CasesInput))
+	for i, input := range correction.CasesInput {
+		if i < len(correction.CasesExpected) {
+			cases = append(cases, judge.Case{Input: input, Expected: correction.CasesExpected[i]})
+		}
+	}
+	return judge.New(
+		correction.Command,
+		correction.RunCommand,
+		cases,
+		judge.Compare(correction.Compare),
+		correction.Tolerance,
+	)
+}
+
+// grade replaces the output of a graded sheet with the verdict of each case.
+// The status code is 0 only if every case passes.
+func grade(
+	grader *judge.Run,
+	output container.Output,
+	status container.RunResponse,
+) (container.Output, container.RunResponse) {
+	if grader == nil {
+		return output, status
+	}
+	report, passed := grader.Grade(output.Combined())
+	status.StatusCode = 0
+	if !passed {
+		status.StatusCode = 1
+	}
+	return container.NewOutput(report, ""), status
+}
+
+// TestSet tells when a test file of the sheet is run.
+type TestSet string
+
+const (
+	// shown in the exercise panel and run by the Run button
+	TestsVisible TestSet = "visible"
+	// only run on Submit
+	TestsHidden TestSet = "hidden"
+)
+
+// WithTests returns the correction keeping only the test files of the set.
+// The files outside any test set are always kept.
+func WithTests(correction Correction, set TestSet) Correction {
+	filtered := correction
+	filtered.FilesName = nil
+	filtered.FilesContent = nil
+	filtered.FilesEditable = nil
+	filtered.FilesStarter = nil
+	filtered.FilesTestSet = nil
+	for i, name := range correction.FilesName {
+		test := testSet(correction, i)
+		if test != "" && test != set {
+			continue
 		}
+		filtered.FilesName = append(filtered.FilesName, name)
+		filtered.FilesContent = append(filtered.FilesContent, correction.FilesContent[i])
+		if i < len(correction.FilesEditable) {
+			filtered.FilesEditable = append(filtered.FilesEditable, correction.FilesEditable[i])
+			filtered.FilesStarter = append(filtered.FilesStarter, correction.FilesStarter[i])
+		}
+		filtered.FilesTestSet = append(filtered.FilesTestSet, string(test))
 	}
-	return "", container.RunResponse{}, fmt.Errorf("unexpected error in retry loop")
+	return filtered
+}
+
+// HasTests tells whether the sheet has test files in the set.
+func HasTests(correction Correction, set TestSet) bool {
+	for i := range correction.FilesName {
+		if testSet(correction, i) == set {
+			return true
+		}
+	}
+	return false
+}
+
+func testSet(correction Correction, i int) TestSet {
+	if i < len(correction.FilesTestSet) {
+		return TestSet(correction.FilesTestSet[i])
+	}
+	return ""
 }
 
 // Cleanup stops and removes all containers in the pool.
@@ -101,6 +611,12 @@ func (s *ExerciseService) Cleanup() error {
 		return fmt.Errorf("not initialized")
 	}
 
-	s.pool.CleanAll(s.ctx, s.cli)
+	if s.stopReaper != nil {
+		s.stopReaper()
+		if err := s.rt.ForgetInstance(s.ctx, container.Instance); err != nil {
+			log.Printf("Failed to remove the heartbeats: %v", err)
+		}
+	}
+	s.pool.CleanAll(s.ctx, s.rt)
 	return nil
 }
@@ -2,14 +2,20 @@ package services_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"nexzap/internal/db"
 	generated "nexzap/internal/db/generated"
 	services "nexzap/internal/services"
+	"nexzap/internal/services/container"
+	"nexzap/internal/services/judge"
 )
 
 type TestService struct {
@@ -40,20 +46,16 @@ func (s *TestService) runSingleTest(t *testing.T, row generated.FindCorrectionSh
 		SubmissionName: row.SubmissionName,
 		FilesName:      row.FilesName,
 		FilesContent:   row.FilesContent,
-	}, row.CorrectionContent)
+	}, map[string]string{row.SubmissionName: row.CorrectionContent}, container.Waiter{})
 	if err != nil {
 		t.Errorf("RunTest failed: %v", err)
 		return
 	}
-	if status.Error != nil {
-		t.Error(status.Error)
-		return
-	}
 	if status.StatusCode != 0 {
-		t.Errorf("Test failed with error code %d and output %s", status.StatusCode, output)
+		t.Errorf("Test failed with error code %d and output %s", status.StatusCode, output.Combined())
 		return
 	}
-	if output == "" {
+	if output.Combined() == "" {
 		t.Errorf("Expected non-empty output, got empty")
 	}
 }
@@ -124,17 +126,18 @@ func (s *TestService) runFailureTest(t *testing.T, title string, page int32, cod
 		FilesContent:   row.FilesContent,
 	}
 	badPayload := strings.ReplaceAll(row.CorrectionContent, old, new)
-	output, status, err := s.exercise.RunTest(correction, badPayload)
+	output, status, err := s.exercise.RunTest(
+		correction,
+		map[string]string{row.SubmissionName: badPayload},
+		container.Waiter{},
+	)
 	if err != nil {
 		t.Errorf("RunTest failed: %v", err)
 	}
-	if status.Error != nil {
-		t.Error(status.Error)
-	}
 	if status.StatusCode != code {
-		t.Errorf("Test expected code %d but got %d with output %s", code, status.StatusCode, output)
+		t.Errorf("Test expected code %d but got %d with output %s", code, status.StatusCode, output.Combined())
 	}
-	if output == "" {
+	if output.Combined() == "" {
 		t.Errorf("Expected non-empty output, got empty")
 	}
 }
@@ -213,3 +216,337 @@ func TestRunTest_Concurrent(t *testing.T) {
 		})
 	}
 }
+
+func TestRunTest_FakeRuntime(t *testing.T) {
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		if files["main_test.go"] == "" {
+			return container.FakeResult{Stderr: "missing test file", StatusCode: 2}
+		}
+		if files["main.go"] != "solution" {
+			return container.FakeResult{Stdout: "FAIL", StatusCode: 1}
+		}
+		return container.FakeResult{Stdout: "PASS"}
+	})
+	svc := services.NewExerciseServiceWithRuntime(rt)
+	defer func() {
+		if err := svc.Cleanup(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	tests := []struct {
+		payload string
+		code    int64
+	}{
+		{"solution", 0},
+		{"wrong", 1},
+	}
+	for _, mode := range []container.RunMode{container.ModeStart, container.ModeExec} {
+		correction := services.Correction{
+			DockerImage:    "gotest",
+			Command:        "go test",
+			SubmissionName: "main.go",
+			FilesName:      []string{"main.go", "main_test.go"},
+			FilesContent:   []string{"solution", "package main"},
+			PoolMode:       string(mode),
+		}
+		for _, tt := range tests {
+			output, status, err := svc.RunTest(correction, map[string]string{"main.go": tt.payload}, container.Waiter{})
+			if err != nil {
+				t.Fatalf("%s: RunTest failed: %v", mode, err)
+			}
+			if status.StatusCode != tt.code {
+				t.Errorf("%s: expected code %d, got %d with output %s", mode, tt.code, status.StatusCode, output.Combined())
+			}
+		}
+	}
+}
+
+func TestRunTest_MultipleFiles(t *testing.T) {
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		if files["lib.rs"] != "lib solution" || files["helper.rs"] != "helper solution" {
+			return container.FakeResult{Stdout: files["lib.rs"] + "|" + files["helper.rs"], StatusCode: 1}
+		}
+		if files["tests.rs"] != "tests" {
+			return container.FakeResult{Stderr: "missing test file", StatusCode: 2}
+		}
+		return container.FakeResult{Stdout: "PASS"}
+	})
+	svc := services.NewExerciseServiceWithRuntime(rt)
+	defer svc.Cleanup()
+
+	correction := services.Correction{
+		DockerImage:    "rusttest",
+		Command:        "cargo test",
+		SubmissionName: "lib.rs",
+		FilesName:      []string{"lib.rs", "helper.rs", "tests.rs"},
+		FilesContent:   []string{"lib solution", "helper solution", "tests"},
+		FilesEditable:  []bool{true, true, false},
+		FilesStarter:   []string{"lib starter", "helper starter", ""},
+	}
+	tests := []struct {
+		name       string
+		submission map[string]string
+		code       int64
+		output     string
+	}{
+		{"all files", map[string]string{"lib.rs": "lib solution", "helper.rs": "helper solution"}, 0, "PASS"},
+		// the starter is used rather than the correction for a missing file
+		{"missing file", map[string]string{"lib.rs": "lib solution"}, 1, "lib solution|helper starter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, status, err := svc.RunTest(correction, tt.submission, container.Waiter{})
+			if err != nil {
+				t.Fatalf("RunTest failed: %v", err)
+			}
+			if status.StatusCode != tt.code || !strings.Contains(output.Combined(), tt.output) {
+				t.Errorf("expected code %d with %q, got %d with output %s", tt.code, tt.output, status.StatusCode, output.Combined())
+			}
+		})
+	}
+
+	_, _, err := svc.RunTest(correction, map[string]string{"tests.rs": "cheat"}, container.Waiter{})
+	if !errors.Is(err, services.ErrNotEditable) {
+		t.Errorf("expected ErrNotEditable, got %v", err)
+	}
+}
+
+func TestDrain_WaitsForRunsInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		if files["main.go"] == "slow" {
+			close(started)
+			<-release
+		}
+		return container.FakeResult{Stdout: "PASS"}
+	})
+	svc := services.NewExerciseServiceWithRuntime(rt)
+	defer svc.Cleanup()
+
+	correction := services.Correction{
+		DockerImage:    "gotest",
+		Command:        "go test",
+		SubmissionName: "main.go",
+		FilesName:      []string{"main.go"},
+		FilesContent:   []string{"solution"},
+	}
+	done := make(chan error)
+	go func() {
+		_, _, err := svc.RunTest(correction, map[string]string{"main.go": "slow"}, container.Waiter{})
+		done <- err
+	}()
+	<-started
+
+	drained := make(chan error)
+	go func() {
+		drained <- svc.Drain(context.Background())
+	}()
+	// Wait for Drain to refuse new runs
+	for {
+		_, _, err := svc.RunTest(correction, map[string]string{"main.go": "solution"}, container.Waiter{})
+		if errors.Is(err, services.ErrShuttingDown) {
+			break
+		}
+	}
+	select {
+	case <-drained:
+		t.Fatal("Drain returned before the run in flight finished")
+	default:
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("run in flight failed: %v", err)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("Drain failed: %v", err)
+	}
+}
+
+func TestWithTests(t *testing.T) {
+	correction := services.Correction{
+		SubmissionName: "main.go",
+		FilesName:      []string{"go.mod", "main.go", "visible_test.go", "hidden_test.go"},
+		FilesContent:   []string{"module main", "solution", "visible", "hidden"},
+		FilesEditable:  []bool{false, true, false, false},
+		FilesStarter:   []string{"", "starter", "", ""},
+		FilesTestSet:   []string{"", "", "visible", "hidden"},
+	}
+	tests := []struct {
+		set   services.TestSet
+		files []string
+	}{
+		{services.TestsVisible, []string{"go.mod", "main.go", "visible_test.go"}},
+		{services.TestsHidden, []string{"go.mod", "main.go", "hidden_test.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.set), func(t *testing.T) {
+			filtered := services.WithTests(correction, tt.set)
+			if !slices.Equal(filtered.FilesName, tt.files) {
+				t.Errorf("expected files %v, got %v", tt.files, filtered.FilesName)
+			}
+			if len(filtered.FilesContent) != len(tt.files) || len(filtered.FilesEditable) != len(tt.files) {
+				t.Errorf("file columns out of sync: %+v", filtered)
+			}
+			if !services.HasTests(correction, tt.set) {
+				t.Errorf("expected tests in set %s", tt.set)
+			}
+		})
+	}
+
+	// Sheets without test sets keep every file
+	correction.FilesTestSet = nil
+	if got := services.WithTests(correction, services.TestsVisible).FilesName; len(got) != 4 {
+		t.Errorf("expected every file, got %v", got)
+	}
+	if services.HasTests(correction, services.TestsHidden) {
+		t.Error("expected no hidden tests")
+	}
+}
+
+func TestRunTest_IOGrading(t *testing.T) {
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		if !slices.Equal(tutorial.Command, judge.Command()) || files[judge.SCRIPT] == "" {
+			return container.FakeResult{Stderr: "not run by the judge", StatusCode: 2}
+		}
+		if files[".nexzap/cases/1.in"] != "2\n" {
+			return container.FakeResult{Stderr: "missing case input", StatusCode: 2}
+		}
+		// the script is killed before reporting any case
+		return container.FakeResult{Stdout: "Killed"}
+	})
+	svc := services.NewExerciseServiceWithRuntime(rt)
+	defer svc.Cleanup()
+
+	correction := services.Correction{
+		DockerImage:    "cobol",
+		Command:        "cobc -x main.cob",
+		RunCommand:     "./main",
+		Grading:        string(services.GradingIO),
+		Compare:        string(judge.Trimmed),
+		SubmissionName: "main.cob",
+		FilesName:      []string{"main.cob"},
+		FilesContent:   []string{"solution"},
+		CasesInput:     []string{"2\n"},
+		CasesExpected:  []string{"4\n"},
+	}
+	output, status, err := svc.RunTest(correction, map[string]string{"main.cob": "wrong"}, container.Waiter{})
+	if err != nil {
+		t.Fatalf("RunTest failed: %v", err)
+	}
+	if status.StatusCode != 1 || !strings.Contains(output.Stdout, "not ok 1 - case 1") {
+		t.Errorf("expected the case to fail, got %d with output %s", status.StatusCode, output.Combined())
+	}
+
+	var stream strings.Builder
+	status, err = svc.RunTestStream(correction, map[string]string{"main.cob": "wrong"}, container.Waiter{}, &stream, &stream)
+	if err != nil {
+		t.Fatalf("RunTestStream failed: %v", err)
+	}
+	if status.StatusCode != 1 || !strings.Contains(stream.String(), "Killed\n1..1\nnot ok 1") {
+		t.Errorf("expected the report to be streamed, got %d with output %s", status.StatusCode, stream.String())
+	}
+}
+
+func TestRunScratch(t *testing.T) {
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		if _, ok := files["main_test.go"]; ok {
+			return container.FakeResult{Stderr: "correction files copied", StatusCode: 2}
+		}
+		if tutorial.Limits.Timeout > services.SCRATCH_TIMEOUT {
+			return container.FakeResult{Stderr: "limits not applied", StatusCode: 2}
+		}
+		if files["main.go"] == "loop" {
+			time.Sleep(500 * time.Millisecond)
+		}
+		return container.FakeResult{
+			Stdout:     strings.Join(tutorial.Command, " ") + "|" + files["main.go"] + "|" + files[services.SCRATCH_STDIN],
+			Stderr:     "warning",
+			StatusCode: 3,
+		}
+	})
+	svc := services.NewExerciseServiceWithRuntime(rt)
+	defer svc.Cleanup()
+
+	correction := services.Correction{
+		DockerImage:        "gotest",
+		Command:            "go test",
+		TutorialRunCommand: "go run .",
+		SubmissionName:     "main.go",
+		FilesName:          []string{"main.go", "main_test.go"},
+		FilesContent:       []string{"solution", "tests"},
+	}
+	result, err := svc.RunScratch(correction, map[string]string{"main.go": "code"}, "input", container.Waiter{})
+	if err != nil {
+		t.Fatalf("RunScratch failed: %v", err)
+	}
+	expected := services.ScratchResult{
+		Stdout:     "sh -c go run . < " + services.SCRATCH_STDIN + "|code|input",
+		Stderr:     "warning",
+		StatusCode: 3,
+	}
+	if result != expected {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+
+	correction.Timeout = 50
+	result, err = svc.RunScratch(correction, map[string]string{"main.go": "loop"}, "", container.Waiter{})
+	if err != nil || !result.TimedOut {
+		t.Errorf("expected the run to time out, got %+v, %v", result, err)
+	}
+
+	correction.TutorialRunCommand = ""
+	if _, err := svc.RunScratch(correction, nil, "", container.Waiter{}); !errors.Is(err, services.ErrNoScratchpad) {
+		t.Errorf("expected ErrNoScratchpad, got %v", err)
+	}
+}
+
+func TestRunTest_Limits(t *testing.T) {
+	var runs atomic.Int32
+	rt := container.NewFakeRuntime(func(tutorial container.Tutorial, files map[string]string) container.FakeResult {
+		runs.Add(1)
+		switch files["main.go"] {
+		case "loop":
+			time.Sleep(500 * time.Millisecond)
+		case "alloc":
+			return container.FakeResult{OOMKilled: true}
+		}
+		return container.FakeResult{}
+	})
+	svc := services.NewExerciseServiceWithRuntime(rt)
+	defer svc.Cleanup()
+
+	for _, mode := range []container.RunMode{container.ModeStart, container.ModeExec} {
+		correction := services.Correction{
+			DockerImage:    "gotest",
+			Command:        "go test",
+			SubmissionName: "main.go",
+			FilesName:      []string{"main.go"},
+			FilesContent:   []string{"solution"},
+			Timeout:        50,
+			PoolMode:       string(mode),
+		}
+		runs.Store(0)
+		_, status, err := svc.RunTest(correction, map[string]string{"main.go": "loop"}, container.Waiter{})
+		if err != nil {
+			t.Fatalf("%s: RunTest failed: %v", mode, err)
+		}
+		if !status.TimedOut || status.StatusCode == 0 {
+			t.Errorf("%s: expected the run to time out, got %+v", mode, status)
+		}
+		// the timeout is not retried
+		if runs.Load() != 1 {
+			t.Errorf("%s: expected a single run, got %d", mode, runs.Load())
+		}
+
+		_, status, err = svc.RunTest(correction, map[string]string{"main.go": "alloc"}, container.Waiter{})
+		if err != nil {
+			t.Fatalf("%s: RunTest failed: %v", mode, err)
+		}
+		if !status.OOMKilled || status.StatusCode != container.STATUS_KILLED {
+			t.Errorf("%s: expected the run to be killed for its memory, got %+v", mode, status)
+		}
+	}
+}
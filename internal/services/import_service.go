@@ -2,29 +2,39 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strings"
 	"time"
 
 	"nexzap/internal/db"
 	generated "nexzap/internal/db/generated"
+	"nexzap/internal/services/container"
+	"nexzap/internal/services/judge"
+	"nexzap/internal/services/report"
 
 	"github.com/BurntSushi/toml"
+	"github.com/docker/go-units"
 )
 
 type ImportService struct {
 	numberRegex *regexp.Regexp
 	db          *db.Database
+	builder     container.ImageBuilder
 }
 
-func NewImportService(db *db.Database) *ImportService {
+func NewImportService(db *db.Database, builder container.ImageBuilder) *ImportService {
 	return &ImportService{
 		numberRegex: regexp.MustCompile(`^\d+`),
 		db:          db,
+		builder:     builder,
 	}
 }
 
@@ -42,7 +52,7 @@ func (s *ImportService) RefreshTutorials() error {
 		path := filepath.Join("tutorials", tutorialDir.Name())
 		err = s.ImportTutorialFromDir(path)
 		if err != nil {
-			fmt.Printf("Failed to import %s\n", path)
+			fmt.Printf("Failed to import %s: %v\n", path, err)
 		}
 	}
 
@@ -51,9 +61,19 @@ func (s *ImportService) RefreshTutorials() error {
 
 // ImportTutorialFromDir reads a single tutorial directory and inserts it into the database.
 func (s *ImportService) ImportTutorialFromDir(path string) error {
+	_, err := s.importTutorial(path)
+	return err
+}
+
+// importTutorial inserts the tutorial of the directory and returns its metadata.
+func (s *ImportService) importTutorial(path string) (*tutorialMeta, error) {
 	meta, sheets, err := s.readDirectory(path)
 	if err != nil {
-		return fmt.Errorf("Failed to read tutorial directory: %s. Error: %v", path, err)
+		return nil, fmt.Errorf("Failed to read tutorial directory: %s. Error: %v", path, err)
+	}
+	digests, err := s.resolveImages(path, *sheets)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to resolve the images of %s: %v", path, err)
 	}
 
 	// Construct tutorial and files per sheet
@@ -61,7 +81,18 @@ func (s *ImportService) ImportTutorialFromDir(path string) error {
 	guides := []string{}
 	exercises := []string{}
 	images := []string{}
+	imageDigests := []string{}
 	commands := []string{}
+	parsers := []string{}
+	memories := []int64{}
+	cpus := []float64{}
+	timeouts := []int64{}
+	pidsLimits := []int64{}
+	outputLimits := []int64{}
+	gradings := []string{}
+	runCommands := []string{}
+	compares := []string{}
+	tolerances := []float64{}
 	submissionName := []string{}
 	submissionContent := []string{}
 	correctionContent := []string{}
@@ -71,43 +102,166 @@ func (s *ImportService) ImportTutorialFromDir(path string) error {
 		guides = append(guides, sheet.guide)
 		exercises = append(exercises, sheet.exercise)
 		images = append(images, sheet.Image)
+		imageDigests = append(imageDigests, digests[sheet.Image])
 		commands = append(commands, sheet.Command)
+		parsers = append(parsers, sheet.Parser)
+		memories = append(memories, sheet.limits.Memory)
+		cpus = append(cpus, sheet.limits.CPUs)
+		timeouts = append(timeouts, sheet.limits.Timeout.Milliseconds())
+		pidsLimits = append(pidsLimits, sheet.limits.PidsLimit)
+		outputLimits = append(outputLimits, sheet.limits.OutputLimit)
+		gradings = append(gradings, sheet.Grading)
+		runCommands = append(runCommands, sheet.Run)
+		compares = append(compares, sheet.Compare)
+		tolerances = append(tolerances, sheet.Tolerance)
 		submissionName = append(submissionName, sheet.SubmissionName)
 		submissionContent = append(submissionContent, sheet.submissionContent)
 		correctionContent = append(correctionContent, sheet.correctionContent)
 
-		filesName := []string{}
-		filesContent := []string{}
+		files := FilesPerSheet{}
 		for _, f := range sheet.files {
-			filesName = append(filesName, f.Name)
-			filesContent = append(filesContent, f.Content)
+			position := slices.Index(sheet.editables, f.Name)
+			files.Names = append(files.Names, f.Name)
+			files.Contents = append(files.Contents, f.Content)
+			files.Editables = append(files.Editables, position >= 0)
+			files.Starters = append(files.Starters, sheet.starters[f.Name])
+			files.Positions = append(files.Positions, int32(max(position, 0)))
+			files.TestSets = append(files.TestSets, string(sheet.testSets[f.Name]))
 		}
-		filesPerSheet = append(filesPerSheet, FilesPerSheet{
-			Names:    filesName,
-			Contents: filesContent,
-		})
+		for _, c := range sheet.cases {
+			files.Inputs = append(files.Inputs, c.Input)
+			files.Expected = append(files.Expected, c.Expected)
+		}
+		filesPerSheet = append(filesPerSheet, files)
+	}
+
+	cachePaths := []string{}
+	cacheEnvs := []string{}
+	for _, cache := range meta.Caches {
+		cachePaths = append(cachePaths, cache.Path)
+		cacheEnvs = append(cacheEnvs, cache.Env)
 	}
 
 	tutorial := generated.InsertTutorialParams{
-		Title:              meta.Title,
-		CodeEditor:         meta.CodeEditor,
-		Version:            int32(meta.Version),
-		Unlock:             meta.UnlockTime,
-		Pages:              pages,
-		GuidesContent:      guides,
-		ExercisesContent:   exercises,
-		DockerImages:       images,
-		Commands:           commands,
-		SubmissionsName:    submissionName,
-		SubmissionsContent: submissionContent,
-		CorrectionContent:  correctionContent,
+		Title:                meta.Title,
+		CodeEditor:           meta.CodeEditor,
+		Version:              int32(meta.Version),
+		Unlock:               meta.UnlockTime,
+		PoolMin:              int32(meta.pool.Min),
+		PoolMax:              int32(meta.pool.Max),
+		PoolLanguageTimeout:  meta.pool.LanguageTimeout.Milliseconds(),
+		PoolContainerTimeout: meta.pool.ContainerTimeout.Milliseconds(),
+		PoolMode:             string(meta.mode),
+		SandboxUser:          meta.sandbox.User,
+		SandboxWritable:      meta.sandbox.Writable,
+		SandboxWorkspaceSize: meta.sandbox.WorkspaceSize,
+		SandboxNofile:        meta.sandbox.NoFile,
+		SandboxNproc:         meta.sandbox.NProc,
+		SandboxSeccomp:       meta.sandbox.Seccomp,
+		CachePaths:           cachePaths,
+		CacheEnvs:            cacheEnvs,
+		RunCommand:           meta.Run,
+		Pages:                pages,
+		GuidesContent:        guides,
+		ExercisesContent:     exercises,
+		DockerImages:         images,
+		ImageDigests:         imageDigests,
+		Commands:             commands,
+		Parsers:              parsers,
+		Memories:             memories,
+		Cpus:                 cpus,
+		Timeouts:             timeouts,
+		PidsLimits:           pidsLimits,
+		OutputLimits:         outputLimits,
+		Gradings:             gradings,
+		RunCommands:          runCommands,
+		Compares:             compares,
+		Tolerances:           tolerances,
+		SubmissionsName:      submissionName,
+		SubmissionsContent:   submissionContent,
+		CorrectionContent:    correctionContent,
 	}
 
 	if err := s.insertTutorialAndFiles(tutorial, filesPerSheet); err != nil {
-		return fmt.Errorf("Failed to insert tutorial %s: %v", meta.Title, err)
+		return nil, fmt.Errorf("Failed to insert tutorial %s: %v", meta.Title, err)
 	}
 
-	return nil
+	return meta, nil
+}
+
+// resolveImages returns the id of each image used by the sheets, indexed by name.
+// The docker/ directory of a tutorial builds a single image.
+func (s *ImportService) resolveImages(path string, sheets []sheet) (map[string]string, error) {
+	digests := make(map[string]string)
+	for _, sheet := range sheets {
+		digests[sheet.Image] = ""
+	}
+	_, err := os.Stat(filepath.Join(path, "docker"))
+	if err == nil && len(digests) > 1 {
+		return nil, fmt.Errorf("docker/ builds a single image but the sheets use %d", len(digests))
+	}
+	for image := range digests {
+		if digests[image], err = s.ResolveImage(path, image); err != nil {
+			return nil, err
+		}
+	}
+	return digests, nil
+}
+
+// ResolveImage builds the image from the docker/ directory of the tutorial and
+// returns its id. The image is only tagged with the hash of the directory, so
+// it is only built again when its content changes, and a draft never replaces
+// the image other tutorials find by its name. Without docker/ directory, the
+// image must already exist.
+func (s *ImportService) ResolveImage(path string, image string) (string, error) {
+	ctx := context.Background()
+	dockerDir := filepath.Join(path, "docker")
+	if _, err := os.Stat(dockerDir); os.IsNotExist(err) {
+		id, err := s.builder.ImageID(ctx, image)
+		if err != nil {
+			return "", fmt.Errorf("image %s not found: %v", image, err)
+		}
+		return id, nil
+	}
+
+	var files []file
+	if err := s.readCodeFiles(dockerDir, "", &files); err != nil {
+		return "", err
+	}
+	tag := imageRepository(image) + ":" + hashFiles(files)
+	if id, err := s.builder.ImageID(ctx, tag); err == nil {
+		return id, nil
+	}
+
+	fmt.Printf("Building image %s from %s\n", tag, dockerDir)
+	buildContext := make([]container.File, 0, len(files))
+	for _, f := range files {
+		buildContext = append(buildContext, container.File{Name: f.Name, Content: f.Content, Mode: f.Mode})
+	}
+	if err := s.builder.BuildImage(ctx, buildContext, []string{tag}); err != nil {
+		return "", fmt.Errorf("failed to build image %s: %v", tag, err)
+	}
+	return s.builder.ImageID(ctx, tag)
+}
+
+// imageRepository strips the tag of an image name.
+func imageRepository(image string) string {
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i]
+	}
+	return image
+}
+
+// hashFiles returns a short hash of the names, the modes and the contents of
+// the files.
+func hashFiles(files []file) string {
+	sorted := append([]file{}, files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	h := sha256.New()
+	for _, f := range sorted {
+		fmt.Fprintf(h, "%s\x00%o\x00%d\x00%s", f.Name, f.Mode.Perm(), len(f.Content), f.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
 }
 
 // insertTutorialAndFiles inserts a tutorial and its associated files per sheet
@@ -128,13 +282,33 @@ func (s *ImportService) insertTutorialAndFiles(
 	}
 	for i, sheetID := range sheetsID {
 		fileInsert := generated.InsertFilesParams{
-			Names:    filesPerSheet[i].Names,
-			Contents: filesPerSheet[i].Contents,
-			SheetID:  sheetID,
+			Names:           filesPerSheet[i].Names,
+			Contents:        filesPerSheet[i].Contents,
+			SheetID:         sheetID,
+			Editables:       filesPerSheet[i].Editables,
+			StartersContent: filesPerSheet[i].Starters,
+			Positions:       filesPerSheet[i].Positions,
+			TestSets:        filesPerSheet[i].TestSets,
 		}
 		if err := s.db.GetRepository().InsertFiles(context.Background(), fileInsert); err != nil {
 			return err
 		}
+		if len(filesPerSheet[i].Inputs) == 0 {
+			continue
+		}
+		positions := make([]int32, len(filesPerSheet[i].Inputs))
+		for j := range positions {
+			positions[j] = int32(j + 1)
+		}
+		caseInsert := generated.InsertCasesParams{
+			SheetID:   sheetID,
+			Positions: positions,
+			Inputs:    filesPerSheet[i].Inputs,
+			Expected:  filesPerSheet[i].Expected,
+		}
+		if err := s.db.GetRepository().InsertCases(context.Background(), caseInsert); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -145,12 +319,49 @@ type tutorialMeta struct {
 	CodeEditor string    `toml:"codeEditor"`
 	Version    int       `toml:"version"`
 	UnlockTime time.Time `toml:"unlock"`
+	Pool       poolMeta  `toml:"pool"`
+	pool       container.PoolPolicy
+	mode       container.RunMode
+	Sandbox    sandboxMeta `toml:"sandbox"`
+	sandbox    container.Sandbox
+	// Build caches baked in the image, copied for each run
+	Caches []cacheMeta `toml:"cache"`
+	// Command running the code of the scratchpad, e.g. "go run .", none when empty
+	Run string `toml:"run"`
+}
+
+// poolMeta sizes the pool of the containers of the tutorial while it is the current one.
+// The defaults of the server are used when unset.
+type poolMeta struct {
+	Min              *int   `toml:"min"` // 0 is a valid value
+	Max              int    `toml:"max"`
+	LanguageTimeout  string `toml:"language_timeout"`  // e.g. "10m"
+	ContainerTimeout string `toml:"container_timeout"` // e.g. "30s"
+	Mode             string `toml:"mode"`              // "start" (default) or "exec"
+}
+
+// sandboxMeta hardens the containers of the tutorial. The defaults of the
+// server are used when unset.
+type sandboxMeta struct {
+	User          string `toml:"user"`           // e.g. "1000:1000", never a name
+	Writable      bool   `toml:"writable"`       // keeps the root filesystem writable
+	WorkspaceSize string `toml:"workspace_size"` // e.g. "64m"
+	NoFile        int64  `toml:"nofile"`
+	NProc         int64  `toml:"nproc"`
+	Seccomp       string `toml:"seccomp"` // JSON profile, relative to the tutorial directory
+}
+
+// cacheMeta is a build cache prepared by the docker/ directory of the tutorial.
+type cacheMeta struct {
+	Path string `toml:"path"` // in the image, e.g. "/cache/go"
+	Env  string `toml:"env"`  // e.g. "GOCACHE"
 }
 
 // file represents a file with correction content for a tutorial sheet.
 type file struct {
 	Name    string
 	Content string
+	Mode    os.FileMode
 }
 
 // toml key must be exported
@@ -159,10 +370,32 @@ type sheet struct {
 	exercise          string
 	submissionContent string
 	correctionContent string
-	SubmissionName    string `toml:"submission"`
-	Image             string `toml:"image"`
-	Command           string `toml:"command"`
+	SubmissionName    string   `toml:"submission"`
+	Submissions       []string `toml:"submissions"` // other files the learner edits
+	Image             string   `toml:"image"`
+	Command           string   `toml:"command"`
+	Parser            string   `toml:"parser"`
+	VisibleTests      []string `toml:"visible_tests"` // shown and run with the Run button
+	HiddenTests       []string `toml:"hidden_tests"`  // only run on Submit
 	files             []file
+	editables         []string          // editable files in the order of the tabs
+	starters          map[string]string // content of the editable files before any edit
+	testSets          map[string]TestSet
+
+	// Sheets graded on the output of the submission, the command builds it
+	Grading   string  `toml:"grading"` // "test" (default) or "io"
+	Run       string  `toml:"run"`     // runs the submission once per case
+	Compare   string  `toml:"compare"`
+	Tolerance float64 `toml:"tolerance"` // for the "float" comparison
+	cases     []judge.Case
+
+	// Resources of the container, the defaults of the server are used when unset
+	Memory      string  `toml:"memory"` // e.g. "256m"
+	CPUs        float64 `toml:"cpus"`
+	Timeout     string  `toml:"timeout"` // e.g. "45s"
+	PidsLimit   int64   `toml:"pids_limit"`
+	OutputLimit string  `toml:"output_limit"` // e.g. "64k"
+	limits      container.Limits
 }
 
 // readDirectory reads a tutorial directory, returning metadata and sheets.
@@ -231,6 +464,20 @@ func (s *ImportService) extractMeta(dir []os.DirEntry, path string) (*tutorialMe
 	if meta.Version == 0 {
 		return nil, errors.New("version field is not set in meta.toml")
 	}
+	if meta.pool, err = s.parsePool(meta.Pool); err != nil {
+		return nil, fmt.Errorf("invalid pool in meta.toml: %v", err)
+	}
+	if meta.mode, err = container.ParseRunMode(meta.Pool.Mode); err != nil {
+		return nil, fmt.Errorf("invalid pool in meta.toml: %v", err)
+	}
+	if meta.sandbox, err = s.parseSandbox(meta.Sandbox, path); err != nil {
+		return nil, fmt.Errorf("invalid sandbox in meta.toml: %v", err)
+	}
+	for _, cache := range meta.Caches {
+		if err := (container.Cache{Path: cache.Path, Env: cache.Env}).Validate(); err != nil {
+			return nil, fmt.Errorf("invalid cache in meta.toml: %v", err)
+		}
+	}
 
 	return &meta, nil
 }
@@ -251,10 +498,30 @@ func (s *ImportService) readGuide(dir os.DirEntry, basePath string) (sheet, erro
 	if err != nil {
 		return sheet{}, err
 	}
+	if !report.Exists(sheetMeta.Parser) {
+		return sheet{}, fmt.Errorf("unknown parser %q in %s, expected one of %v", sheetMeta.Parser, metaPath, report.Names())
+	}
+	limits, err := s.parseLimits(sheetMeta)
+	if err != nil {
+		return sheet{}, fmt.Errorf("invalid limits in %s: %v", metaPath, err)
+	}
+	if err := s.checkGrading(&sheetMeta); err != nil {
+		return sheet{}, fmt.Errorf("invalid grading in %s: %v", metaPath, err)
+	}
+	var cases []judge.Case
+	if Grading(sheetMeta.Grading) == GradingIO {
+		if cases, err = s.readCases(dirPath); err != nil {
+			return sheet{}, err
+		}
+	}
 
 	var correctionFiles []file
 
-	paths, err := s.findFiles(dirPath, sheetMeta.SubmissionName)
+	editables := s.editableNames(sheetMeta)
+	if len(editables) == 0 {
+		return sheet{}, fmt.Errorf("no submission file in %s", metaPath)
+	}
+	paths, err := s.findFiles(dirPath, editables[0])
 	if err != nil {
 		return sheet{}, err
 	}
@@ -265,6 +532,11 @@ func (s *ImportService) readGuide(dir os.DirEntry, basePath string) (sheet, erro
 		return sheet{}, err
 	}
 
+	testSets, err := s.testSets(sheetMeta, editables, correctionFiles)
+	if err != nil {
+		return sheet{}, fmt.Errorf("invalid tests in %s: %v", metaPath, err)
+	}
+
 	guideContent, err := os.ReadFile(paths.Guide)
 	if err != nil {
 		return sheet{}, err
@@ -280,6 +552,13 @@ func (s *ImportService) readGuide(dir os.DirEntry, basePath string) (sheet, erro
 		return sheet{}, err
 	}
 
+	starters := map[string]string{editables[0]: string(submissionContent)}
+	for _, name := range editables[1:] {
+		if starters[name], err = s.readStarter(dirPath, name, correctionFiles); err != nil {
+			return sheet{}, err
+		}
+	}
+
 	correctionContent, err := os.ReadFile(paths.Correction)
 	if err != nil {
 		return sheet{}, err
@@ -290,15 +569,208 @@ func (s *ImportService) readGuide(dir os.DirEntry, basePath string) (sheet, erro
 		exercise:          string(exerciseContent),
 		Image:             sheetMeta.Image,
 		Command:           sheetMeta.Command,
-		SubmissionName:    sheetMeta.SubmissionName,
+		Parser:            sheetMeta.Parser,
+		limits:            limits,
+		SubmissionName:    editables[0],
 		submissionContent: string(submissionContent),
 		correctionContent: string(correctionContent),
 		files:             correctionFiles,
+		editables:         editables,
+		starters:          starters,
+		testSets:          testSets,
+		Grading:           sheetMeta.Grading,
+		Run:               sheetMeta.Run,
+		Compare:           sheetMeta.Compare,
+		Tolerance:         sheetMeta.Tolerance,
+		cases:             cases,
 	}
 
 	return sheet, nil
 }
 
+// editableNames lists the files the learner edits, the submission first.
+func (s *ImportService) editableNames(meta sheet) []string {
+	names := []string{}
+	for _, name := range append([]string{meta.SubmissionName}, meta.Submissions...) {
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// checkGrading checks the grading of the sheet meta.toml and fills its defaults.
+// The verdict of each case of an io sheet is reported with TAP.
+func (s *ImportService) checkGrading(meta *sheet) error {
+	switch Grading(meta.Grading) {
+	case "", GradingTest:
+		meta.Grading = string(GradingTest)
+		return nil
+	case GradingIO:
+	default:
+		return fmt.Errorf("unknown grading %q, expected %q or %q", meta.Grading, GradingTest, GradingIO)
+	}
+	if meta.Run == "" {
+		return errors.New("run command required")
+	}
+	if meta.Parser != "" && meta.Parser != "tap" {
+		return fmt.Errorf("parser %q can't be used, the cases are reported with tap", meta.Parser)
+	}
+	meta.Parser = "tap"
+	if meta.Compare == "" {
+		meta.Compare = string(judge.Trimmed)
+	}
+	if !judge.Compare(meta.Compare).Valid() {
+		return fmt.Errorf("unknown compare %q, expected one of %v", meta.Compare, judge.Compares())
+	}
+	if meta.Tolerance < 0 {
+		return errors.New("tolerance must be positive")
+	}
+	return nil
+}
+
+// readCases reads the cases of an io sheet from its cases/ directory, each
+// <name>.in file giving the input and <name>.out the expected output.
+// They are sorted by name.
+func (s *ImportService) readCases(dirPath string) ([]judge.Case, error) {
+	casesDir := filepath.Join(dirPath, "cases")
+	inputs, err := filepath.Glob(filepath.Join(casesDir, "*.in"))
+	if err != nil {
+		return nil, err
+	}
+	if len(inputs) == 0 {
+		return nil, errors.New("no case found in " + casesDir)
+	}
+	sort.Strings(inputs)
+	cases := []judge.Case{}
+	for _, inputPath := range inputs {
+		input, err := os.ReadFile(inputPath)
+		if err != nil {
+			return nil, err
+		}
+		expectedPath := strings.TrimSuffix(inputPath, ".in") + ".out"
+		expected, err := os.ReadFile(expectedPath)
+		if err != nil {
+			return nil, errors.New("expected output not found at " + expectedPath)
+		}
+		cases = append(cases, judge.Case{Input: string(input), Expected: string(expected)})
+	}
+	return cases, nil
+}
+
+// testSets returns the test set of the test files by name. They must be
+// correction files the learner doesn't edit.
+func (s *ImportService) testSets(meta sheet, editables []string, correctionFiles []file) (map[string]TestSet, error) {
+	sets := map[string]TestSet{}
+	for set, names := range map[TestSet][]string{TestsVisible: meta.VisibleTests, TestsHidden: meta.HiddenTests} {
+		for _, name := range names {
+			if _, ok := sets[name]; ok {
+				return nil, fmt.Errorf("%s is both visible and hidden", name)
+			}
+			if slices.Contains(editables, name) {
+				return nil, fmt.Errorf("%s is edited by the learner", name)
+			}
+			if !slices.ContainsFunc(correctionFiles, func(f file) bool { return f.Name == name }) {
+				return nil, fmt.Errorf("%s not found in correction", name)
+			}
+			sets[name] = set
+		}
+	}
+	return sets, nil
+}
+
+// readStarter reads the starter content of an editable file, next to the
+// meta.toml like the submission. The file must also exist in the correction.
+func (s *ImportService) readStarter(dirPath, name string, correctionFiles []file) (string, error) {
+	if !slices.ContainsFunc(correctionFiles, func(f file) bool { return f.Name == name }) {
+		return "", errors.New("correction file not found at " + filepath.Join(dirPath, "correction", name))
+	}
+	path := filepath.Join(dirPath, name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path = filepath.Join(dirPath, filepath.Base(name))
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("submission file not found at %s", path)
+	}
+	return string(content), nil
+}
+
+// parseLimits converts the human readable limits of the sheet meta.toml.
+func (s *ImportService) parseLimits(meta sheet) (container.Limits, error) {
+	var limits container.Limits
+	var err error
+	if meta.Memory != "" {
+		if limits.Memory, err = units.RAMInBytes(meta.Memory); err != nil {
+			return limits, fmt.Errorf("memory: %v", err)
+		}
+	}
+	if meta.OutputLimit != "" {
+		if limits.OutputLimit, err = units.RAMInBytes(meta.OutputLimit); err != nil {
+			return limits, fmt.Errorf("output_limit: %v", err)
+		}
+	}
+	if meta.Timeout != "" {
+		if limits.Timeout, err = time.ParseDuration(meta.Timeout); err != nil {
+			return limits, fmt.Errorf("timeout: %v", err)
+		}
+	}
+	if meta.CPUs < 0 || meta.PidsLimit < 0 {
+		return limits, errors.New("cpus and pids_limit must be positive")
+	}
+	limits.CPUs = meta.CPUs
+	limits.PidsLimit = meta.PidsLimit
+	return limits, nil
+}
+
+// parsePool converts the human readable pool of the tutorial meta.toml.
+func (s *ImportService) parsePool(meta poolMeta) (container.PoolPolicy, error) {
+	policy := container.PoolPolicy{Min: -1, Max: meta.Max}
+	var err error
+	if meta.Min != nil {
+		policy.Min = *meta.Min
+	}
+	if (meta.Min != nil && *meta.Min < 0) || meta.Max < 0 {
+		return policy, errors.New("min and max must be positive")
+	}
+	if meta.LanguageTimeout != "" {
+		if policy.LanguageTimeout, err = time.ParseDuration(meta.LanguageTimeout); err != nil {
+			return policy, fmt.Errorf("language_timeout: %v", err)
+		}
+	}
+	if meta.ContainerTimeout != "" {
+		if policy.ContainerTimeout, err = time.ParseDuration(meta.ContainerTimeout); err != nil {
+			return policy, fmt.Errorf("container_timeout: %v", err)
+		}
+	}
+	return policy, nil
+}
+
+// parseSandbox converts the human readable sandbox of the tutorial meta.toml
+// and reads its seccomp profile.
+func (s *ImportService) parseSandbox(meta sandboxMeta, path string) (container.Sandbox, error) {
+	sandbox := container.Sandbox{
+		User:     meta.User,
+		Writable: meta.Writable,
+		NoFile:   meta.NoFile,
+		NProc:    meta.NProc,
+	}
+	var err error
+	if meta.WorkspaceSize != "" {
+		if sandbox.WorkspaceSize, err = units.RAMInBytes(meta.WorkspaceSize); err != nil {
+			return sandbox, fmt.Errorf("workspace_size: %v", err)
+		}
+	}
+	if meta.Seccomp != "" {
+		content, err := os.ReadFile(filepath.Join(path, meta.Seccomp))
+		if err != nil {
+			return sandbox, fmt.Errorf("seccomp: %v", err)
+		}
+		sandbox.Seccomp = string(content)
+	}
+	return sandbox, sandbox.Validate()
+}
+
 // FilePaths holds the paths to various files in a tutorial sheet.
 type FilePaths struct {
 	Guide      string
@@ -370,9 +842,14 @@ func (s *ImportService) readCodeFiles(dirPath, subDir string, files *[]file) err
 			if err != nil {
 				return err
 			}
+			info, err := os.Stat(filepath.Join(dirPath, entry.Name()))
+			if err != nil {
+				return err
+			}
 			*files = append(*files, file{
 				Name:    filepath.Join(subDir, entry.Name()),
 				Content: string(content),
+				Mode:    info.Mode().Perm(),
 			})
 		}
 	}
@@ -381,6 +858,13 @@ func (s *ImportService) readCodeFiles(dirPath, subDir string, files *[]file) err
 
 // FilesPerSheet defines file data for a single sheet
 type FilesPerSheet struct {
-	Names    []string
-	Contents []string
+	Names     []string
+	Contents  []string
+	Editables []bool
+	Starters  []string
+	Positions []int32
+	TestSets  []string
+	// cases of the io sheets
+	Inputs   []string
+	Expected []string
 }
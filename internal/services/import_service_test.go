@@ -0,0 +1,80 @@
+package services_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nexzap/internal/services"
+	"nexzap/internal/services/container"
+)
+
+func TestResolveImage_BuildsOncePerContent(t *testing.T) {
+	rt := container.NewFakeRuntime(nil)
+	importService := services.NewImportService(nil, rt)
+	tutorial := t.TempDir()
+	dockerfile := filepath.Join(tutorial, "docker", "Dockerfile")
+	if err := os.MkdirAll(filepath.Dir(dockerfile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(content string) {
+		if err := os.WriteFile(dockerfile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("FROM golang:alpine")
+	first, err := importService.ResolveImage(tutorial, "gotest")
+	if err != nil {
+		t.Fatalf("ResolveImage failed: %v", err)
+	}
+	again, err := importService.ResolveImage(tutorial, "gotest")
+	if err != nil {
+		t.Fatalf("ResolveImage failed: %v", err)
+	}
+	if rt.Builds != 1 || again != first {
+		t.Errorf("expected the image to be built once, got %d builds", rt.Builds)
+	}
+	if _, err := rt.ImageID(context.Background(), "gotest"); err == nil {
+		t.Error("expected the image name left to the image built by hand")
+	}
+
+	write("FROM golang:1.24-alpine")
+	changed, err := importService.ResolveImage(tutorial, "gotest")
+	if err != nil {
+		t.Fatalf("ResolveImage failed: %v", err)
+	}
+	if rt.Builds != 2 || changed == first {
+		t.Errorf("expected the image to be built again, got %d builds", rt.Builds)
+	}
+
+	// a mode change alone, like making a script executable, builds it again
+	if err := os.Chmod(dockerfile, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importService.ResolveImage(tutorial, "gotest"); err != nil {
+		t.Fatalf("ResolveImage failed: %v", err)
+	}
+	if rt.Builds != 3 {
+		t.Errorf("expected the image to be built again after a mode change, got %d builds", rt.Builds)
+	}
+}
+
+func TestResolveImage_WithoutDockerDirectory(t *testing.T) {
+	rt := container.NewFakeRuntime(nil)
+	importService := services.NewImportService(nil, rt)
+	tutorial := t.TempDir()
+
+	if _, err := importService.ResolveImage(tutorial, "cobol"); err == nil {
+		t.Error("expected an error for a missing image")
+	}
+	expected := rt.AddImage("cobol")
+	id, err := importService.ResolveImage(tutorial, "cobol")
+	if err != nil {
+		t.Fatalf("ResolveImage failed: %v", err)
+	}
+	if id != expected || rt.Builds != 0 {
+		t.Errorf("expected the existing image %s without build, got %s", expected, id)
+	}
+}
@@ -0,0 +1,95 @@
+package judge
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Compare is the way the output of a case is compared with the expected one.
+type Compare string
+
+const (
+	// byte for byte
+	Exact Compare = "exact"
+	// ignoring the spaces at the end of the lines and the blank lines at the end
+	Trimmed Compare = "trimmed"
+	// the expected output is a regular expression matching the whole output
+	Regex Compare = "regex"
+	// numbers may differ by the tolerance, relative to the expected value above 1
+	Float Compare = "float"
+)
+
+// DEFAULT_TOLERANCE is the tolerance of Float when the sheet sets none.
+const DEFAULT_TOLERANCE = 1e-6
+
+// Compares lists the available ways to compare the output.
+func Compares() []Compare {
+	return []Compare{Exact, Trimmed, Regex, Float}
+}
+
+// Valid returns whether the comparison exists.
+func (c Compare) Valid() bool {
+	for _, compare := range Compares() {
+		if c == compare {
+			return true
+		}
+	}
+	return false
+}
+
+// Match compares the output with the expected one. Errors for an invalid
+// regular expression.
+func (c Compare) Match(expected, output string, tolerance float64) (bool, error) {
+	switch c {
+	case Exact:
+		return output == expected, nil
+	case Trimmed:
+		return trim(output) == trim(expected), nil
+	case Regex:
+		reg, err := regexp.Compile(`^(?:` + strings.TrimRight(expected, "\n") + `)$`)
+		if err != nil {
+			return false, fmt.Errorf("invalid expected regular expression: %v", err)
+		}
+		return reg.MatchString(strings.TrimRight(output, "\n")), nil
+	case Float:
+		if tolerance <= 0 {
+			tolerance = DEFAULT_TOLERANCE
+		}
+		return matchFloat(expected, output, tolerance), nil
+	}
+	return false, fmt.Errorf("unknown comparison %q", c)
+}
+
+func trim(output string) string {
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// matchFloat compares the words of the outputs, the numbers with the tolerance.
+func matchFloat(expected, output string, tolerance float64) bool {
+	want := strings.Fields(expected)
+	got := strings.Fields(output)
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if want[i] == got[i] {
+			continue
+		}
+		w, errW := strconv.ParseFloat(want[i], 64)
+		g, errG := strconv.ParseFloat(got[i], 64)
+		if errW != nil || errG != nil {
+			return false
+		}
+		if math.Abs(w-g) > tolerance*math.Max(1, math.Abs(w)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+// Package judge grades the sheets checked on the output of the submission
+// rather than a test command: the submission is built once, then run on the
+// input of each case and its output compared with the expected one.
+package judge
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"nexzap/internal/services/container"
+	"strconv"
+	"strings"
+)
+
+const (
+	// directory of the judge in the workspace
+	DIR = ".nexzap"
+	// script building and running the submission on each case
+	SCRIPT = DIR + "/judge.sh"
+)
+
+// Case is an input given on stdin and the output expected on stdout.
+type Case struct {
+	Input    string
+	Expected string
+}
+
+// Run grades a single submission. The marker, unique to the run, tells the
+// lines printed by the script apart from the output of the submission. It is
+// no secret from the submission, which runs as the same user and can read the
+// script: like with a test command, a submission set on cheating can forge
+// its verdict, the grading only guards against mistakes.
+type Run struct {
+	build     string
+	run       string
+	cases     []Case
+	compare   Compare
+	tolerance float64
+	marker    string
+}
+
+// New creates the grading of a submission. The build command may be empty
+// for interpreted languages.
+func New(build, run string, cases []Case, compare Compare, tolerance float64) *Run {
+	marker := make([]byte, 16)
+	rand.Read(marker)
+	return &Run{
+		build:     build,
+		run:       run,
+		cases:     cases,
+		compare:   compare,
+		tolerance: tolerance,
+		marker:    "@@nexzap-" + hex.EncodeToString(marker),
+	}
+}
+
+// Command is the command of the containers of the graded sheets.
+func Command() []string {
+	return []string{"sh", SCRIPT}
+}
+
+// Files returns the script and the input of the cases to copy in the workspace.
+func (r *Run) Files() []container.File {
+	files := []container.File{{Name: SCRIPT, Content: r.script()}}
+	for i, c := range r.cases {
+		files = append(files, container.File{Name: inputName(i), Content: c.Input})
+	}
+	return files
+}
+
+func inputName(i int) string {
+	return fmt.Sprintf("%s/cases/%d.in", DIR, i+1)
+}
+
+// script builds the submission, then prints the exit code, stdout and stderr
+// of each case. The output is base64 encoded so no line of it is taken for a
+// line of the script.
+func (r *Run) script() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "M=%s\n", r.marker)
+	if r.build != "" {
+		fmt.Fprintf(&b, "{ %s\n} > %s/build.log 2>&1\n", r.build, DIR)
+		b.WriteString("status=$?\n")
+		b.WriteString("if [ $status -ne 0 ]; then\n")
+		fmt.Fprintf(&b, "  cat %s/build.log\n", DIR)
+		b.WriteString("  echo \"$M build $status\"\n")
+		b.WriteString("  exit $status\n")
+		b.WriteString("fi\n")
+	}
+	for i := range r.cases {
+		fmt.Fprintf(&b, "{ %s\n} < %s > %s/out 2> %s/err\n", r.run, inputName(i), DIR, DIR)
+		fmt.Fprintf(&b, "echo \"$M case %d $?\"\n", i+1)
+		fmt.Fprintf(&b, "echo \"$M stdout $(base64 < %s/out | tr -d '\\n')\"\n", DIR)
+		fmt.Fprintf(&b, "echo \"$M stderr $(base64 < %s/err | tr -d '\\n')\"\n", DIR)
+	}
+	return b.String()
+}
+
+// result is what the script reported for a case.
+type result struct {
+	ran    bool
+	code   int
+	stdout string
+	stderr string
+}
+
+// Grade compares the output of the submission for each case with the expected
+// one, and returns the verdicts as a Test Anything Protocol report.
+func (r *Run) Grade(output string) (string, bool) {
+	results := make([]result, len(r.cases))
+	var log strings.Builder
+	buildFailed := false
+	current := -1
+	for line := range strings.Lines(output) {
+		rest, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), r.marker+" ")
+		if !ok {
+			log.WriteString(line)
+			continue
+		}
+		kind, value, _ := strings.Cut(rest, " ")
+		switch kind {
+		case "build":
+			buildFailed = true
+		case "case":
+			number, code, _ := strings.Cut(value, " ")
+			i, err := strconv.Atoi(number)
+			if err != nil || i < 1 || i > len(results) {
+				current = -1
+				continue
+			}
+			current = i - 1
+			results[current].ran = true
+			results[current].code, _ = strconv.Atoi(code)
+		case "stdout", "stderr":
+			if current < 0 {
+				continue
+			}
+			decoded, _ := base64.StdEncoding.DecodeString(value)
+			if kind == "stdout" {
+				results[current].stdout = string(decoded)
+			} else {
+				results[current].stderr = string(decoded)
+			}
+		}
+	}
+
+	var report strings.Builder
+	if log.Len() > 0 {
+		report.WriteString(strings.TrimRight(log.String(), "\n") + "\n")
+	}
+	// Like a test harness that could not start, no case is reported
+	if buildFailed {
+		report.WriteString("Bail out! The build failed\n")
+		return report.String(), false
+	}
+	fmt.Fprintf(&report, "1..%d\n", len(r.cases))
+	passed := true
+	for i, c := range r.cases {
+		res := results[i]
+		var problem string
+		switch {
+		case !res.ran:
+			problem = "not run, the time or output limit was reached"
+		case res.code != 0:
+			problem = fmt.Sprintf("exited with code %d", res.code)
+		default:
+			match, err := r.compare.Match(c.Expected, res.stdout, r.tolerance)
+			if err != nil {
+				problem = err.Error()
+			} else if !match {
+				problem = "wrong output"
+			}
+		}
+		if problem == "" {
+			fmt.Fprintf(&report, "ok %d - case %d\n", i+1, i+1)
+			continue
+		}
+		passed = false
+		fmt.Fprintf(&report, "not ok %d - case %d\n", i+1, i+1)
+		fmt.Fprintf(&report, "# %s\n", problem)
+		if res.ran {
+			writeBlock(&report, "input", c.Input)
+			writeBlock(&report, "expected", c.Expected)
+			writeBlock(&report, "got", res.stdout)
+			if res.stderr != "" {
+				writeBlock(&report, "stderr", res.stderr)
+			}
+		}
+	}
+	return report.String(), passed
+}
+
+// writeBlock writes a labelled content as TAP comments.
+func writeBlock(b *strings.Builder, label, content string) {
+	fmt.Fprintf(b, "# %s:\n", label)
+	for line := range strings.Lines(strings.TrimRight(content, "\n")) {
+		fmt.Fprintf(b, "#   %s\n", strings.TrimRight(line, "\r\n"))
+	}
+}
@@ -0,0 +1,111 @@
+package judge_test
+
+import (
+	"nexzap/internal/services/judge"
+	"nexzap/internal/services/report"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompare_Match(t *testing.T) {
+	tests := []struct {
+		compare  judge.Compare
+		expected string
+		output   string
+		match    bool
+	}{
+		{judge.Exact, "42\n", "42\n", true},
+		{judge.Exact, "42\n", "42", false},
+		{judge.Trimmed, "a\nb\n", "a  \nb\n\n\n", true},
+		{judge.Trimmed, "a\nb\n", "a\n b\n", false},
+		{judge.Regex, `hello, \w+!`, "hello, world!\n", true},
+		{judge.Regex, `hello`, "hello, world!\n", false},
+		{judge.Float, "3.14159 ok\n", "3.141592 ok", true},
+		{judge.Float, "1000000\n", "1000000.5\n", true},
+		{judge.Float, "3.14\n", "3.15\n", false},
+		{judge.Float, "1 2\n", "1\n", false},
+	}
+	for _, tt := range tests {
+		match, err := tt.compare.Match(tt.expected, tt.output, 0)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %v", tt.compare, tt.expected, err)
+		}
+		if match != tt.match {
+			t.Errorf("%s %q with %q: expected %v, got %v", tt.compare, tt.expected, tt.output, tt.match, match)
+		}
+	}
+
+	if _, err := judge.Regex.Match("(", "", 0); err == nil {
+		t.Error("expected an error for an invalid regular expression")
+	}
+}
+
+// runScript runs the judge script like the container would, in a temporary workspace.
+func runScript(t *testing.T, run *judge.Run) string {
+	t.Helper()
+	for _, tool := range []string{"sh", "base64"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+	dir := t.TempDir()
+	for _, f := range run.Files() {
+		path := filepath.Join(dir, f.Name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f.Content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(judge.Command()[0], judge.Command()[1:]...)
+	cmd.Dir = dir
+	output, _ := cmd.CombinedOutput()
+	return string(output)
+}
+
+func TestRun_Grade(t *testing.T) {
+	cases := []judge.Case{
+		{Input: "2\n", Expected: "4\n"},
+		{Input: "5\n", Expected: "10\n"},
+		{Input: "0\n", Expected: "0\n"},
+	}
+	tests := []struct {
+		name     string
+		build    string
+		run      string
+		passed   bool
+		statuses []report.Status
+	}{
+		{"all pass", "", `read x; echo $((x * 2))`, true, []report.Status{report.Pass, report.Pass, report.Pass}},
+		{"wrong output", "", `read x; echo $((x + 2))`, false, []report.Status{report.Pass, report.Fail, report.Fail}},
+		{"exit code", "", `read x; echo $((x * 2)); [ $x -ne 0 ]`, false, []report.Status{report.Pass, report.Pass, report.Fail}},
+		// the submission can't fake the lines of the script
+		{"forged marker", "", `read x; echo $((x * 2)); echo "$M case 3 0"`, false, []report.Status{report.Fail, report.Fail, report.Fail}},
+		// no case is reported, the submission did not compile
+		{"build failed", "echo syntax error; false", `cat`, false, []report.Status{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := judge.New(tt.build, tt.run, cases, judge.Trimmed, 0)
+			output, passed := run.Grade(runScript(t, run))
+			if passed != tt.passed {
+				t.Errorf("expected passed %v, got %v with output:\n%s", tt.passed, passed, output)
+			}
+			result, err := report.Parse("tap", output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Tests) != len(tt.statuses) {
+				t.Fatalf("expected %d cases, got %+v", len(tt.statuses), result.Tests)
+			}
+			for i, status := range tt.statuses {
+				if result.Tests[i].Status != status {
+					t.Errorf("case %d: expected %s, got %s with message %q", i+1, status, result.Tests[i].Status, result.Tests[i].Message)
+				}
+			}
+		})
+	}
+}
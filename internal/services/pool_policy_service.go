@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"log"
+	"nexzap/internal/db"
+	generated "nexzap/internal/db/generated"
+	"nexzap/internal/services/container"
+	"time"
+)
+
+// number of always-up containers for the images of the current tutorial,
+// unless its meta.toml sets its own
+const CURRENT_MIN_CTN = 6
+
+// PoolPolicyService decides how the container pool of each image is sized
+// from the tutorials using it.
+type PoolPolicyService struct {
+	db *db.Database
+}
+
+func NewPoolPolicyService(database *db.Database) *PoolPolicyService {
+	return &PoolPolicyService{
+		db: database,
+	}
+}
+
+type TutorialPool = generated.FindImagePoolsRow
+
+// PoolPolicy returns the policy of an image. Falls back to the default policy
+// when the tutorials can't be read.
+func (s *PoolPolicyService) PoolPolicy(image string) container.PoolPolicy {
+	tutorials, err := s.db.GetRepository().FindImagePools(context.Background(), image)
+	if err != nil {
+		log.Printf("Failed to find the pool of %s: %v", image, err)
+		return container.DefaultPoolPolicy()
+	}
+	return ResolvePoolPolicy(tutorials)
+}
+
+// ResolvePoolPolicy merges the pools of the tutorials using an image.
+// The current tutorial gets a large warm pool while the archived ones and
+// the ones not yet unlocked get none. Unknown images keep the default policy.
+func ResolvePoolPolicy(tutorials []TutorialPool) container.PoolPolicy {
+	if len(tutorials) == 0 {
+		return container.DefaultPoolPolicy()
+	}
+	var policy container.PoolPolicy
+	for _, tu := range tutorials {
+		policy.Max = max(policy.Max, int(tu.PoolMax))
+		policy.LanguageTimeout = max(policy.LanguageTimeout, time.Duration(tu.PoolLanguageTimeout)*time.Millisecond)
+		policy.ContainerTimeout = max(policy.ContainerTimeout, time.Duration(tu.PoolContainerTimeout)*time.Millisecond)
+		if !tu.IsCurrent {
+			continue
+		}
+		if tu.PoolMin >= 0 {
+			policy.Min = int(tu.PoolMin)
+		} else {
+			policy.Min = CURRENT_MIN_CTN
+		}
+	}
+	return policy.WithDefaults()
+}
@@ -0,0 +1,49 @@
+package services_test
+
+import (
+	"nexzap/internal/services"
+	"nexzap/internal/services/container"
+	"testing"
+	"time"
+)
+
+func TestResolvePoolPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		tutorials []services.TutorialPool
+		expected  container.PoolPolicy
+	}{
+		{
+			name:      "unknown image",
+			tutorials: nil,
+			expected:  container.DefaultPoolPolicy(),
+		},
+		{
+			name:      "archived",
+			tutorials: []services.TutorialPool{{PoolMin: -1}},
+			expected:  container.PoolPolicy{Min: 0}.WithDefaults(),
+		},
+		{
+			name:      "current",
+			tutorials: []services.TutorialPool{{PoolMin: -1}, {PoolMin: -1, IsCurrent: true}},
+			expected:  container.PoolPolicy{Min: services.CURRENT_MIN_CTN}.WithDefaults(),
+		},
+		{
+			name: "current from meta",
+			tutorials: []services.TutorialPool{
+				{PoolMin: 20, PoolMax: 4, IsCurrent: true, PoolLanguageTimeout: 600000},
+				{PoolMin: -1, PoolMax: 30},
+			},
+			expected: container.PoolPolicy{Min: 20, Max: 30, LanguageTimeout: 10 * time.Minute}.WithDefaults(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := services.ResolvePoolPolicy(tt.tutorials)
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
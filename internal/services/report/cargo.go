@@ -0,0 +1,50 @@
+package report
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	cargoTestReg    = regexp.MustCompile(`test (\S+) \.\.\. (ok|FAILED|ignored)`)
+	cargoFailureReg = regexp.MustCompile(`---- (\S+) stdout ----`)
+)
+
+// parseCargo parses the output of `cargo test`.
+// Cargo does not report the duration of each test on stable.
+func parseCargo(output string) Report {
+	messages := cargoMessages(output)
+	report := Report{Output: output}
+	for _, match := range cargoTestReg.FindAllStringSubmatch(output, -1) {
+		test := TestCase{Name: match[1]}
+		switch match[2] {
+		case "ok":
+			test.Status = Pass
+		case "ignored":
+			test.Status = Skip
+		default:
+			test.Status = Fail
+			test.Message = messages[test.Name]
+		}
+		report.Tests = append(report.Tests, test)
+	}
+	return report
+}
+
+// cargoMessages extracts the stdout section printed for each failed test.
+func cargoMessages(output string) map[string]string {
+	messages := map[string]string{}
+	sections := cargoFailureReg.FindAllStringSubmatchIndex(output, -1)
+	for i, section := range sections {
+		end := len(output)
+		if i+1 < len(sections) {
+			end = sections[i+1][0]
+		}
+		body := output[section[1]:end]
+		if idx := strings.Index(body, "\nfailures:"); idx >= 0 {
+			body = body[:idx]
+		}
+		messages[output[section[2]:section[3]]] = strings.TrimSpace(body)
+	}
+	return messages
+}
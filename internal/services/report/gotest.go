@@ -0,0 +1,79 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// goTestEvent is a line of `go test -json`, see `go doc test2json`.
+type goTestEvent struct {
+	Action  string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// parseGoTest parses the output of `go test -json`.
+// Lines that are not JSON, such as build errors, are kept in the output.
+func parseGoTest(output string) Report {
+	var report Report
+	var text strings.Builder
+	messages := map[string]*strings.Builder{}
+	for line := range strings.Lines(output) {
+		var event goTestEvent
+		start := strings.IndexByte(line, '{')
+		if start < 0 || json.Unmarshal([]byte(line[start:]), &event) != nil {
+			text.WriteString(line)
+			continue
+		}
+		text.WriteString(event.Output)
+		if event.Test == "" {
+			continue
+		}
+		switch event.Action {
+		case "output":
+			if _, ok := messages[event.Test]; !ok {
+				messages[event.Test] = &strings.Builder{}
+			}
+			messages[event.Test].WriteString(event.Output)
+		case "pass", "fail", "skip":
+			test := TestCase{
+				Name:     event.Test,
+				Status:   goTestStatus(event.Action),
+				Duration: event.Elapsed,
+			}
+			if msg, ok := messages[event.Test]; ok && test.Status == Fail {
+				test.Message = goTestMessage(msg.String())
+			}
+			report.Tests = append(report.Tests, test)
+		}
+	}
+	report.Output = text.String()
+	return report
+}
+
+func goTestStatus(action string) Status {
+	switch action {
+	case "pass":
+		return Pass
+	case "skip":
+		return Skip
+	default:
+		return Fail
+	}
+}
+
+// goTestMessage keeps the lines written by the test, without the
+// "=== RUN" and "--- FAIL" lines added by the test runner.
+func goTestMessage(output string) string {
+	var msg strings.Builder
+	for line := range strings.Lines(output) {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") {
+			continue
+		}
+		msg.WriteString(trimmed)
+		msg.WriteString("\n")
+	}
+	return strings.TrimSpace(msg.String())
+}
@@ -0,0 +1,84 @@
+package report
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+type junitSuites struct {
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	Error     *junitFailure `xml:"error"`
+	Skipped   *struct{}     `xml:"skipped"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// parseJUnit parses a JUnit XML report printed by the test command.
+// Any text before the report is ignored.
+func parseJUnit(output string) Report {
+	report := Report{Output: output}
+	start := strings.Index(output, "<testsuite")
+	if start < 0 {
+		return report
+	}
+	var suites junitSuites
+	content := output[start:]
+	if strings.HasPrefix(content, "<testsuites") {
+		if err := xml.Unmarshal([]byte(content), &suites); err != nil {
+			return report
+		}
+	} else {
+		var suite junitSuite
+		if err := xml.Unmarshal([]byte(content), &suite); err != nil {
+			return report
+		}
+		suites.Suites = []junitSuite{suite}
+	}
+	for _, suite := range suites.Suites {
+		report.Tests = append(report.Tests, junitCases(suite)...)
+	}
+	return report
+}
+
+func junitCases(suite junitSuite) []TestCase {
+	var tests []TestCase
+	for _, c := range suite.Cases {
+		test := TestCase{Name: c.Name, Status: Pass}
+		if c.Classname != "" {
+			test.Name = c.Classname + "." + c.Name
+		}
+		test.Duration, _ = strconv.ParseFloat(c.Time, 64)
+		failure := c.Failure
+		if failure == nil {
+			failure = c.Error
+		}
+		switch {
+		case failure != nil:
+			test.Status = Fail
+			test.Message = strings.TrimSpace(failure.Message + "\n" + strings.TrimSpace(failure.Text))
+		case c.Skipped != nil:
+			test.Status = Skip
+		}
+		tests = append(tests, test)
+	}
+	for _, sub := range suite.Suites {
+		tests = append(tests, junitCases(sub)...)
+	}
+	return tests
+}
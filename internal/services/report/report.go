@@ -0,0 +1,71 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Status is the outcome of a single test case.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// TestCase is a single test reported by the test command.
+type TestCase struct {
+	Name     string  `json:"name"`
+	Status   Status  `json:"status"`
+	Duration float64 `json:"duration"` // in seconds
+	Message  string  `json:"message,omitempty"`
+}
+
+// Report is the structured result of a test command.
+type Report struct {
+	Tests []TestCase
+	// Output is the human readable output. Parsers of machine formats such as
+	// `go test -json` rebuild it, the others return the raw output.
+	Output string
+}
+
+// Parser turns the output of a test command into a report.
+type Parser func(output string) Report
+
+var parsers = map[string]Parser{
+	"gotest": parseGoTest,
+	"cargo":  parseCargo,
+	"junit":  parseJUnit,
+	"tap":    parseTap,
+}
+
+// Exists returns whether a parser is registered with this name.
+// An empty name is valid and means the output is not parsed.
+func Exists(name string) bool {
+	_, ok := parsers[name]
+	return ok || name == ""
+}
+
+// Names returns the names of the registered parsers.
+func Names() []string {
+	names := make([]string, 0, len(parsers))
+	for name := range parsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse parses the output with the parser registered under the given name.
+// An empty name returns the output without tests.
+func Parse(name, output string) (Report, error) {
+	if name == "" {
+		return Report{Output: output}, nil
+	}
+	parser, ok := parsers[name]
+	if !ok {
+		return Report{Output: output}, fmt.Errorf("unknown parser %q", name)
+	}
+	return parser(output), nil
+}
@@ -0,0 +1,126 @@
+package report_test
+
+import (
+	"nexzap/internal/services/report"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		parser   string
+		input    string
+		expected []report.TestCase
+	}{
+		{
+			name:   "go test json",
+			parser: "gotest",
+			input: `{"Action":"run","Test":"TestAdd"}
+{"Action":"output","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"output","Test":"TestAdd","Output":"    main_test.go:9: Add(1, 2) = 0; want 3\n"}
+{"Action":"output","Test":"TestAdd","Output":"--- FAIL: TestAdd (0.00s)\n"}
+{"Action":"fail","Test":"TestAdd","Elapsed":0.01}
+{"Action":"pass","Test":"TestSub","Elapsed":0.02}
+{"Action":"fail","Elapsed":0.03}
+`,
+			expected: []report.TestCase{
+				{Name: "TestAdd", Status: report.Fail, Duration: 0.01, Message: "main_test.go:9: Add(1, 2) = 0; want 3"},
+				{Name: "TestSub", Status: report.Pass, Duration: 0.02},
+			},
+		},
+		{
+			name:   "cargo test",
+			parser: "cargo",
+			input: `running 3 tests
+test tests::test_add ... ok
+test tests::test_sub ... FAILED
+test tests::test_mul ... ignored
+
+failures:
+
+---- tests::test_sub stdout ----
+assertion failed: 1 == 2
+
+failures:
+    tests::test_sub
+`,
+			expected: []report.TestCase{
+				{Name: "tests::test_add", Status: report.Pass},
+				{Name: "tests::test_sub", Status: report.Fail, Message: "assertion failed: 1 == 2"},
+				{Name: "tests::test_mul", Status: report.Skip},
+			},
+		},
+		{
+			name:   "junit",
+			parser: "junit",
+			input: `compiling...
+<testsuites><testsuite name="main">
+<testcase classname="Main" name="add" time="0.5"/>
+<testcase classname="Main" name="sub" time="0.25"><failure message="expected 3">at line 4</failure></testcase>
+<testcase name="mul"><skipped/></testcase>
+</testsuite></testsuites>`,
+			expected: []report.TestCase{
+				{Name: "Main.add", Status: report.Pass, Duration: 0.5},
+				{Name: "Main.sub", Status: report.Fail, Duration: 0.25, Message: "expected 3\nat line 4"},
+				{Name: "mul", Status: report.Skip},
+			},
+		},
+		{
+			name:   "tap",
+			parser: "tap",
+			input: `TAP version 13
+1..3
+ok 1 - add
+not ok 2 - sub
+  ---
+  message: expected 3
+  duration_ms: 20
+  ...
+ok 3 - mul # SKIP not ready
+`,
+			expected: []report.TestCase{
+				{Name: "add", Status: report.Pass},
+				{Name: "sub", Status: report.Fail, Duration: 0.02, Message: "message: expected 3\nduration_ms: 20"},
+				{Name: "mul", Status: report.Skip},
+			},
+		},
+		{
+			name:     "no parser",
+			parser:   "",
+			input:    "ok",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := report.Parse(tt.parser, tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result.Tests, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, result.Tests)
+			}
+		})
+	}
+}
+
+func TestParse_GoTestOutput(t *testing.T) {
+	input := "# example\n./main.go:3:1: syntax error\n" +
+		`{"Action":"output","Output":"FAIL\texample [build failed]\n"}` + "\n"
+	result, err := report.Parse("gotest", input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "# example\n./main.go:3:1: syntax error\nFAIL\texample [build failed]\n"
+	if result.Output != expected {
+		t.Errorf("expected %q, got %q", expected, result.Output)
+	}
+}
+
+func TestParse_Unknown(t *testing.T) {
+	if _, err := report.Parse("unknown", ""); err == nil {
+		t.Error("expected an error for an unknown parser")
+	}
+}
@@ -0,0 +1,57 @@
+package report
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	tapTestReg     = regexp.MustCompile(`^(not ok|ok)\b\s*\d*\s*(?:-\s*)?([^#]*)(?:#\s*(\w+))?`)
+	tapDurationReg = regexp.MustCompile(`duration_ms:\s*([\d.]+)`)
+)
+
+// parseTap parses a Test Anything Protocol stream.
+// The YAML block and the comments following a test are used as its message.
+func parseTap(output string) Report {
+	report := Report{Output: output}
+	var details []string
+	flush := func() {
+		if len(report.Tests) == 0 {
+			details = nil
+			return
+		}
+		last := &report.Tests[len(report.Tests)-1]
+		block := strings.Join(details, "\n")
+		if match := tapDurationReg.FindStringSubmatch(block); match != nil {
+			ms, _ := strconv.ParseFloat(match[1], 64)
+			last.Duration = ms / 1000
+		}
+		if last.Status == Fail {
+			last.Message = strings.TrimSpace(block)
+		}
+		details = nil
+	}
+	for line := range strings.Lines(output) {
+		line = strings.TrimRight(line, "\r\n")
+		match := tapTestReg.FindStringSubmatch(line)
+		if match == nil {
+			trimmed := strings.TrimSpace(line)
+			if trimmed != "" && trimmed != "---" && trimmed != "..." && !strings.HasPrefix(trimmed, "1..") {
+				details = append(details, strings.TrimPrefix(trimmed, "# "))
+			}
+			continue
+		}
+		flush()
+		test := TestCase{Name: strings.TrimSpace(match[2]), Status: Pass}
+		if match[1] == "not ok" {
+			test.Status = Fail
+		}
+		if directive := strings.ToUpper(match[3]); directive == "SKIP" || directive == "TODO" {
+			test.Status = Skip
+		}
+		report.Tests = append(report.Tests, test)
+	}
+	flush()
+	return report
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"nexzap/internal/db"
+	generated "nexzap/internal/db/generated"
+	"nexzap/internal/services/container"
+)
+
+// ValidateService checks a tutorial before it is published: the correction of
+// each sheet must pass its tests and the starter submission must fail them.
+type ValidateService struct {
+	db       *db.Database
+	imports  *ImportService
+	exercise *ExerciseService
+}
+
+func NewValidateService(
+	database *db.Database,
+	importService *ImportService,
+	exerciseService *ExerciseService,
+) *ValidateService {
+	return &ValidateService{
+		db:       database,
+		imports:  importService,
+		exercise: exerciseService,
+	}
+}
+
+// ValidationRun is the outcome of a submission run during the validation.
+type ValidationRun struct {
+	Output     string
+	StatusCode int64
+	Err        error
+}
+
+// SheetValidation is the outcome of the validation of a sheet.
+type SheetValidation struct {
+	Page       int32
+	Correction ValidationRun
+	Starter    ValidationRun
+}
+
+// Problems lists what prevents the sheet from being published.
+func (v SheetValidation) Problems() []string {
+	problems := []string{}
+	switch {
+	case v.Correction.Err != nil:
+		problems = append(problems, fmt.Sprintf("correction could not run: %v", v.Correction.Err))
+	case v.Correction.StatusCode != 0:
+		problems = append(problems, fmt.Sprintf("correction fails with exit code %d", v.Correction.StatusCode))
+	}
+	switch {
+	case v.Starter.Err != nil:
+		problems = append(problems, fmt.Sprintf("starter submission could not run: %v", v.Starter.Err))
+	case v.Starter.StatusCode == 0:
+		problems = append(problems, "starter submission passes the tests")
+	}
+	return problems
+}
+
+// Ok tells whether the sheet can be published.
+func (v SheetValidation) Ok() bool {
+	return len(v.Problems()) == 0
+}
+
+// Validate imports the tutorial of the directory and runs the correction and
+// the starter submission of each sheet. The database should be a scratch one,
+// as the tutorial is inserted like any other.
+func (s *ValidateService) Validate(path string) ([]SheetValidation, error) {
+	meta, err := s.imports.importTutorial(path)
+	if err != nil {
+		return nil, err
+	}
+	sheets, err := s.db.GetRepository().ListSheetsToValidate(
+		context.Background(),
+		generated.ListSheetsToValidateParams{Title: meta.Title, Version: int32(meta.Version)},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	validations := []SheetValidation{}
+	for _, sheet := range sheets {
+		correction, err := s.db.GetRepository().FindSubmissionData(context.Background(), sheet.ID)
+		if err != nil {
+			return nil, err
+		}
+		validations = append(validations, SheetValidation{
+			Page:       sheet.Page,
+			Correction: s.run(correction, solution(correction)),
+			Starter:    s.run(correction, map[string]string{}),
+		})
+	}
+	return validations, nil
+}
+
+// solution returns the editable files as written in the correction.
+func solution(correction Correction) map[string]string {
+	editable := editableFiles(correction)
+	files := map[string]string{}
+	for i, name := range correction.FilesName {
+		if _, ok := editable[name]; ok {
+			files[name] = correction.FilesContent[i]
+		}
+	}
+	return files
+}
+
+// run runs a submission, the starter content being used for the files missing from it.
+func (s *ValidateService) run(correction Correction, submission map[string]string) ValidationRun {
+	output, status, err := s.exercise.RunTest(correction, submission, container.Waiter{Client: "validate"})
+	return ValidationRun{
+		Output:     output.Combined(),
+		StatusCode: status.StatusCode,
+		Err:        err,
+	}
+}
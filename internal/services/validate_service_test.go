@@ -0,0 +1,61 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+
+	"nexzap/internal/services"
+)
+
+func TestSheetValidation_Problems(t *testing.T) {
+	tests := []struct {
+		name       string
+		validation services.SheetValidation
+		problems   int
+	}{
+		{
+			name: "valid",
+			validation: services.SheetValidation{
+				Correction: services.ValidationRun{StatusCode: 0},
+				Starter:    services.ValidationRun{StatusCode: 1},
+			},
+			problems: 0,
+		},
+		{
+			name: "correction fails",
+			validation: services.SheetValidation{
+				Correction: services.ValidationRun{StatusCode: 1},
+				Starter:    services.ValidationRun{StatusCode: 1},
+			},
+			problems: 1,
+		},
+		{
+			name: "starter passes",
+			validation: services.SheetValidation{
+				Correction: services.ValidationRun{StatusCode: 0},
+				Starter:    services.ValidationRun{StatusCode: 0},
+			},
+			problems: 1,
+		},
+		{
+			name: "runs failed",
+			validation: services.SheetValidation{
+				Correction: services.ValidationRun{Err: errors.New("no container")},
+				Starter:    services.ValidationRun{Err: errors.New("no container")},
+			},
+			problems: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := tt.validation.Problems()
+			if len(problems) != tt.problems {
+				t.Errorf("expected %d problems, got %v", tt.problems, problems)
+			}
+			if tt.validation.Ok() != (tt.problems == 0) {
+				t.Errorf("expected Ok to be %v", tt.problems == 0)
+			}
+		})
+	}
+}
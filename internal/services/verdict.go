@@ -0,0 +1,41 @@
+package services
+
+import (
+	"nexzap/internal/services/container"
+	"nexzap/internal/services/report"
+)
+
+// Verdict sums up the outcome of a submission for the learner.
+type Verdict string
+
+const (
+	VerdictAccepted Verdict = "accepted"
+	// the submission failed before any test was reported
+	VerdictCompileError Verdict = "compile_error"
+	VerdictTestsFailed  Verdict = "tests_failed"
+	VerdictTimeLimit    Verdict = "time_limit_exceeded"
+	VerdictMemoryLimit  Verdict = "memory_limit_exceeded"
+	VerdictOutputLimit  Verdict = "output_limit_exceeded"
+	// the code could not be run, whatever the submission
+	VerdictInternalError Verdict = "internal_error"
+)
+
+// VerdictOf returns the verdict of a finished run, given the tests parsed from
+// its output. A failing run reporting no test did not get to the tests, so it
+// did not compile. Without a parser, it can't be told from failed tests.
+func VerdictOf(status container.RunResponse, parsed bool, tests []report.TestCase) Verdict {
+	switch {
+	case status.TimedOut:
+		return VerdictTimeLimit
+	case status.OOMKilled:
+		return VerdictMemoryLimit
+	case status.OutputTruncated:
+		return VerdictOutputLimit
+	case status.StatusCode == 0:
+		return VerdictAccepted
+	case parsed && len(tests) == 0:
+		return VerdictCompileError
+	default:
+		return VerdictTestsFailed
+	}
+}
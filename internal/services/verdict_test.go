@@ -0,0 +1,36 @@
+package services_test
+
+import (
+	"testing"
+
+	services "nexzap/internal/services"
+	"nexzap/internal/services/container"
+	"nexzap/internal/services/report"
+)
+
+func TestVerdictOf(t *testing.T) {
+	failed := []report.TestCase{{Name: "TestAdd", Status: report.Fail}}
+	tests := []struct {
+		name     string
+		status   container.RunResponse
+		parsed   bool
+		tests    []report.TestCase
+		expected services.Verdict
+	}{
+		{"passed", container.RunResponse{}, true, []report.TestCase{{Name: "TestAdd", Status: report.Pass}}, services.VerdictAccepted},
+		{"failed test", container.RunResponse{StatusCode: 1}, true, failed, services.VerdictTestsFailed},
+		{"no test reported", container.RunResponse{StatusCode: 1}, true, nil, services.VerdictCompileError},
+		// without parser, a build failure can't be told apart
+		{"not parsed", container.RunResponse{StatusCode: 1}, false, nil, services.VerdictTestsFailed},
+		{"timeout", container.RunResponse{StatusCode: container.STATUS_KILLED, TimedOut: true}, true, nil, services.VerdictTimeLimit},
+		{"oom", container.RunResponse{StatusCode: container.STATUS_KILLED, OOMKilled: true}, true, failed, services.VerdictMemoryLimit},
+		{"output", container.RunResponse{StatusCode: container.STATUS_KILLED, OutputTruncated: true}, true, nil, services.VerdictOutputLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if verdict := services.VerdictOf(tt.status, tt.parsed, tt.tests); verdict != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, verdict)
+			}
+		})
+	}
+}
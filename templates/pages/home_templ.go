@@ -40,14 +40,14 @@ func homeContent(sheet models.SheetTempl) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"grid grid-cols-1 md:grid-cols-2 gap-6 h-full\" id=\"submitData\" x-init=\"editor = undefined\" x-data=\"")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"grid grid-cols-1 md:grid-cols-2 gap-6 h-full\" id=\"submitData\" x-init=\"editor = undefined; docs = {}\" x-data=\"")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		var templ_7745c5c3_Var2 string
-		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("submitData({mode:'%s', submission:`%s`, key:'%s'})", sheet.CodeEditor, sheet.SubmissionContent, sheet.Id))
+		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("submitData({mode:'%s', files:%s, tests:%t, scratchpad:%t, key:'%s'})", sheet.CodeEditor, sheet.FilesJSON(), len(sheet.Tests) > 0, sheet.Scratchpad, sheet.Id))
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/pages/home.templ`, Line: 16, Col: 129}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/pages/home.templ`, Line: 16, Col: 181}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
 		if templ_7745c5c3_Err != nil {
@@ -181,7 +181,7 @@ func NextContent(fromHtmx bool, sheet models.SheetTempl, tutorials []models.List
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = partials.ExerciseContent(sheet.ExerciseContent).Render(ctx, templ_7745c5c3_Buffer)
+			templ_7745c5c3_Err = partials.ExerciseContent(sheet).Render(ctx, templ_7745c5c3_Buffer)
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
@@ -191,13 +191,13 @@ func NextContent(fromHtmx bool, sheet models.SheetTempl, tutorials []models.List
 			}
 			var templ_7745c5c3_Var6 string
 			templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf(
-				"updateSheet(`%[2]s`, `%[3]s`); "+
+				"updateSheet(`%[2]s`, %[3]s, %[4]t, %[5]t); "+
 					"const script = document.createElement('script'); "+
 					"script.src = `https://cdnjs.cloudflare.com/ajax/libs/codemirror/5.65.18/mode/%[1]s/%[1]s.min.js`; "+
 					"script.onload = () => { updateMode(`%[1]s`); updateSnippets() }; "+
-					"document.head.appendChild(script); ", sheet.CodeEditor, sheet.Id, sheet.SubmissionContent))
+					"document.head.appendChild(script); ", sheet.CodeEditor, sheet.Id, sheet.FilesJSON(), len(sheet.Tests) > 0, sheet.Scratchpad))
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/pages/home.templ`, Line: 52, Col: 96}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/pages/home.templ`, Line: 52, Col: 130}
 			}
 			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
 			if templ_7745c5c3_Err != nil {
@@ -313,7 +313,7 @@ func submitDataScript(sheet models.SheetTempl) templ.Component {
 			templ_7745c5c3_Var10 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "<script>\n\t\tfunction debounce(fn, delay) {\n\t\t\tlet timeout\n\t\t\treturn function(...args) {\n\t\t\t\tclearTimeout(timeout)\n\t\t\t\ttimeout = setTimeout(() => fn(...args), delay)\n\t\t\t}\n\t\t}\n\n\t\tfunction submitData(props) {\n\t\t\treturn {\n\t\t\t\tloading: false,\n\t\t\t\tkey: props.key,\n\t\t\t\tinitEditor(el) {\n\t\t\t\t\tif (editor) {\n\t\t\t\t\t\tconsole.log(\"Should not init existing\")\n\t\t\t\t\t\treturn\n\t\t\t\t\t}\n\n\t\t\t\t\tconsole.log(\"init new\")\n\t\t\t\t\teditor = CodeMirror.fromTextArea(el, {\n\t\t\t\t\t\tmode: props.mode,\n\t\t\t\t\t\tlineNumbers: true,\n\t\t\t\t\t\tlineSeparator: false,\n\t\t\t\t\t\ttheme: \"daisyui\",\n\t\t\t\t\t\tindentUnit: 4,\n\t\t\t\t\t\tlineWrapping: true,\n\t\t\t\t\t\tautoCloseBrackets: true,\n\t\t\t\t\t\tmatchBrackets: true,\n\t\t\t\t\t})\n\t\t\t\t\t// save to local storage\n\t\t\t\t\tlet saveCode = debounce((cm) => {\n\t\t\t\t\t\tconsole.log(\"saving\")\n\t\t\t\t\t\tthis.code[this.key] = cm.getValue()\n\t\t\t\t\t}, 1000)\n\t\t\t\t\teditor.on(\"change\", (cm) => {\n\t\t\t\t\t\tsaveCode(cm)\n\t\t\t\t\t})\n\t\t\t\t\t// set content\n\t\t\t\t\tif (this.key in this.code && this.code[this.key] !== \"\") {\n\t\t\t\t\t\teditor.setValue(this.code[this.key])\n\t\t\t\t\t} else {\n\t\t\t\t\t\teditor.setValue(props.submission)\n\t\t\t\t\t}\n\t\t\t\t},\n\n\n\t\t\t\tstatusCode: Alpine.$persist({}).as(\"statusCode\"),\n\t\t\t\toutput: Alpine.$persist({}).as(\"output\"),\n\t\t\t\tupdateStatus(event) {\n\t\t\t\t\tresponse = JSON.parse(event.detail.xhr.responseText)\n\t\t\t\t\tthis.statusCode[this.key] = response.statusCode\n\t\t\t\t\tthis.output[this.key] = response.output\n\t\t\t\t},\n\t\t\t\tgetStatusCode() {\n\t\t\t\t\tif (!(this.key in this.statusCode)) {\n\t\t\t\t\t\tthis.statusCode[this.key] = -1\n\t\t\t\t\t}\n\t\t\t\t\treturn this.statusCode[this.key]\n\t\t\t\t},\n\t\t\t\tgetOutput() {\n\t\t\t\t\tif (!(this.key in this.output)) {\n\t\t\t\t\t\tthis.output[this.key] = \"\"\n\t\t\t\t\t}\n\t\t\t\t\treturn this.output[this.key]\n\t\t\t\t},\n\n\t\t\t\tcode: Alpine.$persist({}).as(\"code\"),\n\t\t\t\tgetCode() {\n\t\t\t\t\treturn editor !== undefined ? editor.getValue() : \"\"\n\t\t\t\t},\n\n\n\t\t\t\tkeymapEnable: false,\n\t\t\t\tkeymapMode: \"default\", // TODO : save in persist\n\t\t\t\ttoggleKeymap() {\n\t\t\t\t\tthis.keymapEnable = !this.keymapEnable;\n\t\t\t\t},\n\t\t\t\tsetKeymapMode(content) {\n\t\t\t\t\tthis.keymapMode = content\n\t\t\t\t},\n\t\t\t\tupdateKeymap() {\n\t\t\t\t\tif (enable) {\n\t\t\t\t\t\teditor.setOption(\"keyMap\", this.keymapMode)\n\t\t\t\t\t} else {\n\t\t\t\t\t\teditor.setOption(\"keyMap\", \"default\")\n\t\t\t\t\t}\n\t\t\t\t},\n\n\n\t\t\t\tupdateSheet(key, submission) {\n\t\t\t\t\tthis.key = key\n\t\t\t\t\tif (this.key in this.code && this.code[this.key] !== \"\") {\n\t\t\t\t\t\teditor.setValue(this.code[this.key])\n\t\t\t\t\t} else {\n\t\t\t\t\t\teditor.setValue(submission)\n\t\t\t\t\t}\n\t\t\t\t},\n\t\t\t\tupdateMode(mode) {\n\t\t\t\t\teditor.setOption(\"mode\", mode)\n\t\t\t\t},\n\t\t\t\tgetKey() {\n\t\t\t\t\treturn this.key\n\t\t\t\t},\n\n\t\t\t}\n\t\t}\n\t</script>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "<script>\n\t\tfunction debounce(fn, delay) {\n\t\t\tlet timeout\n\t\t\treturn function(...args) {\n\t\t\t\tclearTimeout(timeout)\n\t\t\t\ttimeout = setTimeout(() => fn(...args), delay)\n\t\t\t}\n\t\t}\n\n\t\t// readEvents parses the Server-Sent Events of a response body, calling\n\t\t// onEvent with the type and data of each. It returns when the body ends.\n\t\tasync function readEvents(body, onEvent) {\n\t\t\tconst reader = body.pipeThrough(new TextDecoderStream()).getReader()\n\t\t\tlet buffer = \"\"\n\t\t\tfor (;;) {\n\t\t\t\tconst {value, done} = await reader.read()\n\t\t\t\tif (done) {\n\t\t\t\t\treturn\n\t\t\t\t}\n\t\t\t\tbuffer += value\n\t\t\t\tlet end\n\t\t\t\twhile ((end = buffer.indexOf(\"\\n\\n\")) >= 0) {\n\t\t\t\t\tlet type = \"message\"\n\t\t\t\t\tlet data = []\n\t\t\t\t\tfor (const line of buffer.slice(0, end).split(\"\\n\")) {\n\t\t\t\t\t\tif (line.startsWith(\"event: \")) {\n\t\t\t\t\t\t\ttype = line.slice(7)\n\t\t\t\t\t\t} else if (line.startsWith(\"data: \")) {\n\t\t\t\t\t\t\tdata.push(line.slice(6))\n\t\t\t\t\t\t}\n\t\t\t\t\t}\n\t\t\t\t\tbuffer = buffer.slice(end + 2)\n\t\t\t\t\tonEvent(type, data.join(\"\\n\"))\n\t\t\t\t}\n\t\t\t}\n\t\t}\n\n\t\tfunction submitData(props) {\n\t\t\treturn {\n\t\t\t\tloading: false,\n\t\t\t\tkey: props.key,\n\t\t\t\tmode: props.mode,\n\t\t\t\t// files of the sheet, one tab each\n\t\t\t\tfiles: [],\n\t\t\t\tcurrent: \"\",\n\t\t\t\t// the sheet has visible tests to run with the Run button\n\t\t\t\thasTests: props.tests,\n\t\t\t\t// the tutorial can run code without the tests\n\t\t\t\tscratchpad: props.scratchpad,\n\t\t\t\tinitEditor(el) {\n\t\t\t\t\tif (editor) {\n\t\t\t\t\t\tconsole.log(\"Should not init existing\")\n\t\t\t\t\t\treturn\n\t\t\t\t\t}\n\n\t\t\t\t\tconsole.log(\"init new\")\n\t\t\t\t\teditor = CodeMirror.fromTextArea(el, {\n\t\t\t\t\t\tmode: props.mode,\n\t\t\t\t\t\tlineNumbers: true,\n\t\t\t\t\t\tlineSeparator: false,\n\t\t\t\t\t\ttheme: \"daisyui\",\n\t\t\t\t\t\tindentUnit: 4,\n\t\t\t\t\t\tlineWrapping: true,\n\t\t\t\t\t\tautoCloseBrackets: true,\n\t\t\t\t\t\tmatchBrackets: true,\n\t\t\t\t\t})\n\t\t\t\t\t// save to local storage\n\t\t\t\t\tlet saveCode = debounce((key, files) => {\n\t\t\t\t\t\tconsole.log(\"saving\")\n\t\t\t\t\t\tthis.code[key] = files\n\t\t\t\t\t}, 1000)\n\t\t\t\t\teditor.on(\"change\", () => {\n\t\t\t\t\t\tsaveCode(this.key, this.getFiles())\n\t\t\t\t\t})\n\t\t\t\t\tthis.loadFiles(props.files)\n\t\t\t\t},\n\t\t\t\t// loadFiles opens a document per file, with the saved code or the starter\n\t\t\t\tloadFiles(files) {\n\t\t\t\t\tlet saved = this.code[this.key] ?? {}\n\t\t\t\t\t// code saved before the sheets had several files\n\t\t\t\t\tif (typeof saved === \"string\") {\n\t\t\t\t\t\tsaved = saved !== \"\" && files.length > 0 ? {[files[0].name]: saved} : {}\n\t\t\t\t\t}\n\t\t\t\t\tdocs = {}\n\t\t\t\t\tfor (const file of files) {\n\t\t\t\t\t\tconst content = saved[file.name] !== undefined && saved[file.name] !== \"\" ? saved[file.name] : file.content\n\t\t\t\t\t\tdocs[file.name] = CodeMirror.Doc(content, this.mode)\n\t\t\t\t\t}\n\t\t\t\t\tthis.files = files\n\t\t\t\t\tif (files.length > 0) {\n\t\t\t\t\t\tthis.selectFile(files[0].name)\n\t\t\t\t\t}\n\t\t\t\t},\n\t\t\t\tselectFile(name) {\n\t\t\t\t\tthis.current = name\n\t\t\t\t\teditor.swapDoc(docs[name])\n\t\t\t\t\teditor.setOption(\"mode\", this.mode)\n\t\t\t\t},\n\n\n\t\t\t\tstatusCode: Alpine.$persist({}).as(\"statusCode\"),\n\t\t\t\toutput: Alpine.$persist({}).as(\"output\"),\n\t\t\t\t// output split by stream in the order it was written, to tell stderr apart\n\t\t\t\tchunks: Alpine.$persist({}).as(\"chunks\"),\n\t\t\t\t// the output went over the limit and the run was stopped\n\t\t\t\ttruncated: Alpine.$persist({}).as(\"truncated\"),\n\t\t\t\tverdict: Alpine.$persist({}).as(\"verdict\"),\n\t\t\t\ttests: Alpine.$persist({}).as(\"tests\"),\n\t\t\t\thidden: Alpine.$persist({}).as(\"hidden\"),\n\t\t\t\t// submitStream runs the code and follows its output, sent back as\n\t\t\t\t// Server-Sent Events in the response of the POST.\n\t\t\t\t// With tests set to \"visible\", the hidden tests are not run.\n\t\t\t\tstreaming: false,\n\t\t\t\tasync submitStream(tests = \"\") {\n\t\t\t\t\tconst key = this.key\n\t\t\t\t\tthis.loading = true\n\t\t\t\t\tthis.streaming = true\n\t\t\t\t\tthis.statusCode[key] = -1\n\t\t\t\t\tthis.output[key] = \"\"\n\t\t\t\t\tthis.chunks[key] = []\n\t\t\t\t\tthis.truncated[key] = false\n\t\t\t\t\tthis.verdict[key] = \"\"\n\t\t\t\t\tthis.tests[key] = []\n\t\t\t\t\tthis.hidden[key] = null\n\t\t\t\t\t// the queue message is replaced by the first output\n\t\t\t\t\tlet queued = false\n\t\t\t\t\tconst append = (type, data) => {\n\t\t\t\t\t\tif (queued) {\n\t\t\t\t\t\t\tthis.output[key] = \"\"\n\t\t\t\t\t\t\tqueued = false\n\t\t\t\t\t\t}\n\t\t\t\t\t\tconst text = JSON.parse(data)\n\t\t\t\t\t\tthis.output[key] += text\n\t\t\t\t\t\tthis.chunks[key].push({stream: type, text: text})\n\t\t\t\t\t}\n\t\t\t\t\tconst handlers = {\n\t\t\t\t\t\t// sent while waiting for a free container\n\t\t\t\t\t\tqueue: (type, data) => {\n\t\t\t\t\t\t\tqueued = true\n\t\t\t\t\t\t\tthis.output[key] = `Waiting in queue, you are #${JSON.parse(data)}`\n\t\t\t\t\t\t},\n\t\t\t\t\t\tstdout: append,\n\t\t\t\t\t\tstderr: append,\n\t\t\t\t\t\tdone: (type, data) => {\n\t\t\t\t\t\t\tconst response = JSON.parse(data)\n\t\t\t\t\t\t\tthis.statusCode[key] = response.statusCode\n\t\t\t\t\t\t\tthis.output[key] = response.output\n\t\t\t\t\t\t\tthis.chunks[key] = response.chunks ?? []\n\t\t\t\t\t\t\tthis.truncated[key] = response.truncated ?? false\n\t\t\t\t\t\t\tthis.verdict[key] = response.verdict ?? \"\"\n\t\t\t\t\t\t\tthis.tests[key] = response.tests ?? []\n\t\t\t\t\t\t\tthis.hidden[key] = response.hidden ?? null\n\t\t\t\t\t\t\tthis.loading = false\n\t\t\t\t\t\t\tthis.streaming = false\n\t\t\t\t\t\t},\n\t\t\t\t\t}\n\t\t\t\t\ttry {\n\t\t\t\t\t\tconst response = await fetch(\"/submit/stream\", {\n\t\t\t\t\t\t\tmethod: \"POST\",\n\t\t\t\t\t\t\tbody: new URLSearchParams({sheet: key, files: JSON.stringify(this.getFiles()), tests: tests}),\n\t\t\t\t\t\t})\n\t\t\t\t\t\tif (!response.ok) {\n\t\t\t\t\t\t\tthrow new Error(await response.text())\n\t\t\t\t\t\t}\n\t\t\t\t\t\tawait readEvents(response.body, (type, data) => handlers[type]?.(type, data))\n\t\t\t\t\t} catch (err) {\n\t\t\t\t\t\tconsole.log(err)\n\t\t\t\t\t}\n\t\t\t\t\t// the stream ended before the done event\n\t\t\t\t\tif (this.streaming) {\n\t\t\t\t\t\tthis.statusCode[key] = 520\n\t\t\t\t\t\tthis.output[key] += \"\\nConnection lost\"\n\t\t\t\t\t\tthis.loading = false\n\t\t\t\t\t\tthis.streaming = false\n\t\t\t\t\t}\n\t\t\t\t},\n\t\t\t\t// runScratch runs the code in the scratchpad, without any test\n\t\t\t\tstdin: \"\",\n\t\t\t\tscratch: {},\n\t\t\t\tasync runScratch() {\n\t\t\t\t\tconst key = this.key\n\t\t\t\t\tthis.loading = true\n\t\t\t\t\ttry {\n\t\t\t\t\t\tconst response = await fetch(\"/run\", {\n\t\t\t\t\t\t\tmethod: \"POST\",\n\t\t\t\t\t\t\tbody: new URLSearchParams({sheet: key, files: JSON.stringify(this.getFiles()), stdin: this.stdin}),\n\t\t\t\t\t\t})\n\t\t\t\t\t\tthis.scratch[key] = await response.json()\n\t\t\t\t\t} catch {\n\t\t\t\t\t\tthis.scratch[key] = {stdout: \"\", stderr: \"Connection lost\", statusCode: 520}\n\t\t\t\t\t}\n\t\t\t\t\tthis.loading = false\n\t\t\t\t},\n\t\t\t\tgetScratch() {\n\t\t\t\t\treturn this.scratch[this.key] ?? null\n\t\t\t\t},\n\t\t\t\tgetStatusCode() {\n\t\t\t\t\tif (!(this.key in this.statusCode)) {\n\t\t\t\t\t\tthis.statusCode[this.key] = -1\n\t\t\t\t\t}\n\t\t\t\t\treturn this.statusCode[this.key]\n\t\t\t\t},\n\t\t\t\tgetOutput() {\n\t\t\t\t\tif (!(this.key in this.output)) {\n\t\t\t\t\t\tthis.output[this.key] = \"\"\n\t\t\t\t\t}\n\t\t\t\t\treturn this.output[this.key]\n\t\t\t\t},\n\t\t\t\tgetChunks() {\n\t\t\t\t\treturn this.chunks[this.key] ?? []\n\t\t\t\t},\n\t\t\t\tgetVerdict() {\n\t\t\t\t\treturn this.verdict[this.key] ?? \"\"\n\t\t\t\t},\n\t\t\t\t// verdictMessage explains a verdict of the submit response\n\t\t\t\tverdictMessage(verdict) {\n\t\t\t\t\treturn {\n\t\t\t\t\t\taccepted: \"All tests passed\",\n\t\t\t\t\t\tcompile_error: \"Compilation failed\",\n\t\t\t\t\t\ttests_failed: \"Some tests failed\",\n\t\t\t\t\t\ttime_limit_exceeded: \"Time limit exceeded, the run was stopped\",\n\t\t\t\t\t\tmemory_limit_exceeded: \"Memory limit exceeded, the run was stopped\",\n\t\t\t\t\t\toutput_limit_exceeded: \"Output limit exceeded, the run was stopped\",\n\t\t\t\t\t\tinternal_error: \"Internal error, the code could not be run\",\n\t\t\t\t\t}[verdict] ?? \"\"\n\t\t\t\t},\n\t\t\t\tgetTruncated() {\n\t\t\t\t\treturn this.truncated[this.key] ?? false\n\t\t\t\t},\n\t\t\t\tgetTests() {\n\t\t\t\t\tif (!(this.key in this.tests)) {\n\t\t\t\t\t\tthis.tests[this.key] = []\n\t\t\t\t\t}\n\t\t\t\t\treturn this.tests[this.key]\n\t\t\t\t},\n\t\t\t\tgetHidden() {\n\t\t\t\t\treturn this.hidden[this.key] ?? null\n\t\t\t\t},\n\n\t\t\t\tcode: Alpine.$persist({}).as(\"code\"),\n\t\t\t\t// getFiles returns the content of each file by name\n\t\t\t\tgetFiles() {\n\t\t\t\t\tconst files = {}\n\t\t\t\t\tfor (const name in docs) {\n\t\t\t\t\t\tfiles[name] = docs[name].getValue()\n\t\t\t\t\t}\n\t\t\t\t\treturn files\n\t\t\t\t},\n\n\n\t\t\t\tkeymapEnable: false,\n\t\t\t\tkeymapMode: \"default\", // TODO : save in persist\n\t\t\t\ttoggleKeymap() {\n\t\t\t\t\tthis.keymapEnable = !this.keymapEnable;\n\t\t\t\t},\n\t\t\t\tsetKeymapMode(content) {\n\t\t\t\t\tthis.keymapMode = content\n\t\t\t\t},\n\t\t\t\tupdateKeymap() {\n\t\t\t\t\tif (enable) {\n\t\t\t\t\t\teditor.setOption(\"keyMap\", this.keymapMode)\n\t\t\t\t\t} else {\n\t\t\t\t\t\teditor.setOption(\"keyMap\", \"default\")\n\t\t\t\t\t}\n\t\t\t\t},\n\n\n\t\t\t\tupdateSheet(key, files, hasTests, scratchpad) {\n\t\t\t\t\tthis.key = key\n\t\t\t\t\tthis.hasTests = hasTests\n\t\t\t\t\tthis.scratchpad = scratchpad\n\t\t\t\t\tthis.loadFiles(files)\n\t\t\t\t},\n\t\t\t\tupdateMode(mode) {\n\t\t\t\t\tthis.mode = mode\n\t\t\t\t\teditor.setOption(\"mode\", mode)\n\t\t\t\t},\n\t\t\t\tgetKey() {\n\t\t\t\t\treturn this.key\n\t\t\t\t},\n\n\t\t\t}\n\t\t}\n\t</script>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -0,0 +1,3 @@
+module nexzap
+
+go 1.24.1